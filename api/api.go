@@ -0,0 +1,14 @@
+// Package api описание HTTP API сервиса в формате OpenAPI и gRPC API в gophermart.proto.
+// Код gRPC генерируется в pkg/gophermartpb
+package api
+
+//go:generate buf generate
+
+import (
+	_ "embed"
+)
+
+// Spec OpenAPI документ api.yml, по нему сервер проверяет запросы и отдаёт его по /api/openapi.yml
+//
+//go:embed api.yml
+var Spec []byte
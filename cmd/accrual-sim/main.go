@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	fastRouter "github.com/fasthttp/router"
+	"github.com/superles/yapgofermart/internal/accrualsim"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	var (
+		endpoint   string
+		logLevel   string
+		scriptFile string
+		cfg        accrualsim.Config
+	)
+
+	flag.StringVar(&endpoint, "a", "localhost:8081", "адрес эндпоинта HTTP-сервера")
+	flag.StringVar(&logLevel, "v", "info", "уровень логирования")
+	flag.StringVar(&scriptFile, "script", "", "json файл сценариев ответов по номерам заказов")
+	flag.BoolVar(&cfg.Random, "random", true, "случайный сценарий для заказов, которых нет в файле сценариев")
+	flag.Float64Var(&cfg.InvalidRate, "invalid-rate", 0.1, "вероятность статуса INVALID для случайного сценария")
+	flag.Float64Var(&cfg.MaxAccrual, "max-accrual", 1000, "максимальное начисление для случайного сценария")
+	flag.DurationVar(&cfg.MinLatency, "min-latency", 0, "минимальная задержка ответа")
+	flag.DurationVar(&cfg.MaxLatency, "max-latency", 0, "максимальная задержка ответа")
+	flag.Float64Var(&cfg.BurstRate, "burst-rate", 0, "вероятность начала серии ответов 429 на запрос")
+	flag.DurationVar(&cfg.BurstDuration, "burst-duration", 5*time.Second, "длительность серии ответов 429")
+	flag.Float64Var(&cfg.ErrorRate, "error-rate", 0, "вероятность ответа 500")
+	flag.Int64Var(&cfg.Seed, "seed", time.Now().UnixNano(), "начальное значение генератора случайных чисел")
+	flag.Usage = func() {
+		_, err := fmt.Fprintf(flag.CommandLine.Output(), "Параметры командной строки симулятора системы расчёта:\n")
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := logger.Initialize(logLevel); err != nil {
+		log.Fatal("ошибка инициализации logger: ", err.Error())
+	}
+
+	if len(scriptFile) > 0 {
+		script, err := accrualsim.LoadScript(scriptFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		cfg.Script = script
+	}
+
+	sim := accrualsim.New(cfg)
+	router := fastRouter.New()
+	router.GET("/api/orders/{number}", sim.OrderHandler)
+
+	srv := fasthttp.Server{Handler: router.Handler}
+
+	appContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+	defer stop()
+
+	go func() {
+		<-appContext.Done()
+		if err := srv.Shutdown(); err != nil {
+			logger.Log.Errorf("fasthttp server shutdown error: %s", err.Error())
+		}
+	}()
+
+	logger.Log.Info(fmt.Sprintf("Accrual simulator started at %s", endpoint))
+
+	if err := srv.ListenAndServe(endpoint); err != nil {
+		log.Fatal("ошибка запуска сервера: ", err.Error())
+	}
+}
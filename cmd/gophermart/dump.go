@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/dump"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"io"
+	"os"
+)
+
+const (
+	dumpFormatJSONL = "jsonl"
+	dumpFormatCSV   = "csv"
+)
+
+// runExport выгрузка всех данных хранилища: jsonl в файл или stdout, csv в каталог
+func runExport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", dumpFormatJSONL, "формат выгрузки jsonl|csv")
+	out := fs.String("out", "", "файл выгрузки jsonl (по умолчанию stdout) или каталог csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var enc dump.Encoder
+	switch *format {
+	case dumpFormatJSONL:
+		w := io.Writer(os.Stdout)
+		if len(*out) > 0 {
+			file, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		enc = dump.NewJSONLEncoder(w)
+	case dumpFormatCSV:
+		if len(*out) == 0 {
+			return errors.New("для формата csv нужен каталог выгрузки -out")
+		}
+		csvEnc, err := dump.NewCSVEncoder(*out)
+		if err != nil {
+			return err
+		}
+		enc = csvEnc
+	default:
+		return fmt.Errorf("неизвестный формат выгрузки %s", *format)
+	}
+
+	store, err := openDumpStorage(cfg)
+	if err != nil {
+		_ = enc.Close()
+		return err
+	}
+	defer closeStorage(store)
+
+	counts, err := dump.Export(ctx, store, enc)
+	if closeErr := enc.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	logger.Log.Infof("выгружено %s", counts)
+	return nil
+}
+
+// runImport загрузка выгрузки в хранилище: jsonl из файла или stdin, csv из каталога
+func runImport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", dumpFormatJSONL, "формат выгрузки jsonl|csv")
+	in := fs.String("in", "", "файл выгрузки jsonl (по умолчанию stdin) или каталог csv")
+	skipExisting := fs.Bool("skip-existing", false, "пропускать записи, уже загруженные в хранилище, для повтора прерванной загрузки")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var dec dump.Decoder
+	switch *format {
+	case dumpFormatJSONL:
+		r := io.Reader(os.Stdin)
+		if len(*in) > 0 {
+			file, err := os.Open(*in)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+		dec = dump.NewJSONLDecoder(r)
+	case dumpFormatCSV:
+		if len(*in) == 0 {
+			return errors.New("для формата csv нужен каталог выгрузки -in")
+		}
+		csvDec, err := dump.NewCSVDecoder(*in)
+		if err != nil {
+			return err
+		}
+		dec = csvDec
+	default:
+		return fmt.Errorf("неизвестный формат выгрузки %s", *format)
+	}
+	defer dec.Close()
+
+	store, err := openDumpStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(store)
+
+	counts, err := dump.Import(ctx, store, dec, *skipExisting)
+	logger.Log.Infof("загружено %s", counts)
+	return err
+}
+
+// openDumpStorage хранилище без кэша: выгрузка и загрузка идут мимо него
+func openDumpStorage(cfg *config.Config) (storage.Storage, error) {
+	if len(cfg.DatabaseDsn) == 0 {
+		return nil, errors.New("не настроена бд")
+	}
+	store, err := newStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка инициализации бд: %w", err)
+	}
+	return store, nil
+}
+
+func closeStorage(store storage.Storage) {
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Log.Errorf("ошибка закрытия хранилища: %s", err.Error())
+		}
+	}
+}
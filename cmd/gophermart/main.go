@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"github.com/superles/yapgofermart/internal/accrual"
 	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/i18n"
 	"github.com/superles/yapgofermart/internal/server"
 	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/metricstorage"
 	"github.com/superles/yapgofermart/internal/storage/pgstorage"
 	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+	"github.com/superles/yapgofermart/internal/utils/tracing"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func main() {
@@ -23,24 +30,86 @@ func main() {
 		log.Fatal("ошибка инициализации logger: ", err.Error())
 	}
 
+	if args := flag.Args(); len(args) > 0 {
+		if err = runCommand(cfg, args); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
 	if len(cfg.Endpoint) == 0 {
 		log.Fatal("не настроен адрес запуска сервера")
 	}
 
-	if len(cfg.AccrualSystemAddress) == 0 {
+	if cfg.AccrualMode == config.AccrualModeHTTP && len(cfg.AccrualSystemAddress) == 0 {
 		log.Fatal("не настроен адрес системы расчёта")
 	}
 
+	if cfg.AccrualRateLimit < 0 || cfg.AccrualRateLimit > accrual.MaxRateLimit {
+		log.Fatalf("ограничение запросов к системе расчёта должно быть от 0 до %d в секунду", accrual.MaxRateLimit)
+	}
+
 	if len(cfg.DatabaseDsn) == 0 {
 		log.Fatal("не настроена бд")
 	}
 
+	if !i18n.Supported(cfg.DefaultLanguage) {
+		log.Fatal("неподдерживаемый язык по умолчанию: ", cfg.DefaultLanguage)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Options{
+		ServiceName: "gophermart",
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		File:        cfg.TracingFile,
+	})
+	if err != nil {
+		log.Fatal("ошибка инициализации трассировки: ", err.Error())
+	}
+
+	pgstorage.Tracer.SetSlowThreshold(cfg.StorageSlowQuery)
+
 	var store storage.Storage
-	if store, err = pgstorage.NewStorage(cfg.DatabaseDsn); err != nil {
+	if store, err = newStorage(cfg); err != nil {
 		log.Fatal("ошибка инициализации бд", err.Error())
 	}
+	if pg, ok := store.(*pgstorage.PgStorage); ok {
+		metrics.Registry.MustRegister(pg.Collector())
+	}
+	if store, err = withCache(context.Background(), store, cfg); err != nil {
+		log.Fatal("ошибка инициализации кэша: ", err.Error())
+	}
+	store = metricstorage.New(store, cfg.StorageSlowQuery)
 
-	service := accrual.Service{Client: accrual.NewHTTPClient(cfg.AccrualSystemAddress), Storage: store}
+	var client accrual.Client
+	switch cfg.AccrualMode {
+	case config.AccrualModeHTTP:
+		client = accrual.NewHTTPClient(cfg.AccrualSystemAddress)
+	case config.AccrualModeLocal:
+		if client, err = accrual.NewLocalClientFromFile(cfg.AccrualRulesFile); err != nil {
+			log.Fatal("ошибка инициализации встроенной системы расчёта: ", err.Error())
+		}
+	default:
+		log.Fatal("неизвестный режим системы расчёта: ", cfg.AccrualMode)
+	}
+
+	service := accrual.Service{
+		Client:            client,
+		Storage:           store,
+		PoolInterval:      cfg.AccrualPollInterval,
+		Workers:           cfg.AccrualWorkers,
+		BatchSize:         cfg.AccrualBatchSize,
+		RateLimit:         cfg.AccrualRateLimit,
+		Adaptive:          cfg.AccrualAdaptive,
+		MaxAge:            cfg.AccrualMaxAge,
+		MaxAttempts:       cfg.AccrualMaxAttempts,
+		ReconcileInterval: cfg.ReconcileInterval,
+		ReconcileSample:   cfg.ReconcileSample,
+		LogRetention:      cfg.AccrualLogRetention,
+	}
+	if len(cfg.AccrualPushKey) > 0 {
+		service.PushDeadline = cfg.AccrualPushDeadline
+	}
 
 	srv := server.New(cfg, store, service)
 	appContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGKILL, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
@@ -51,6 +120,31 @@ func main() {
 		log.Fatal("ошибка запуска сервера: ", err.Error())
 	}
 
+	closeStorage(store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Log.Errorf("ошибка отправки span: %s", err.Error())
+	}
+
 	logger.Log.Info("app graceful shutdown")
 
 }
+
+// runCommand выполнение подкоманды вместо запуска сервера
+func runCommand(cfg *config.Config, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, cfg, args[1:])
+	case "export":
+		return runExport(ctx, cfg, args[1:])
+	case "import":
+		return runImport(ctx, cfg, args[1:])
+	default:
+		return fmt.Errorf("неизвестная команда %s", args[0])
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/storage/pgstorage"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "использование: gophermart [флаги] migrate up|down [количество]|status"
+
+// runMigrate выполнение подкоманды migrate up/down/status
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if len(cfg.DatabaseDsn) == 0 {
+		return errors.New("не настроена бд")
+	}
+
+	if strings.HasPrefix(cfg.DatabaseDsn, sqliteScheme) {
+		return errors.New("миграции SQLite применяются автоматически при запуске")
+	}
+	if strings.HasPrefix(cfg.DatabaseDsn, memScheme) {
+		return errors.New("хранилище в памяти не использует миграции")
+	}
+
+	db, err := pgstorage.Connect(ctx, cfg.DatabaseDsn)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к бд: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := pgstorage.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("применена миграция %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("неверное количество миграций для отката: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("откачена миграция %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		applied := false
+		for _, s := range statuses {
+			applied = applied || s.AppliedAt != nil
+		}
+		if !applied {
+			fmt.Println("миграции не применялись")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/cachestorage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/superles/yapgofermart/internal/storage/pgstorage"
+	"github.com/superles/yapgofermart/internal/storage/sqlitestorage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sqliteScheme = "sqlite://"
+	memScheme    = "mem://"
+)
+
+// defaultCompactInterval период сжатия журнала memstorage по умолчанию
+const defaultCompactInterval = time.Minute
+
+// newStorage выбор хранилища по схеме dsn: sqlite://путь - SQLite,
+// mem:// - в памяти, mem://каталог?compact_interval=1m - в памяти с сохранением в каталог, иначе Postgres
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	dsn := cfg.DatabaseDsn
+	if path, ok := strings.CutPrefix(dsn, sqliteScheme); ok {
+		return sqlitestorage.NewStorage(path)
+	}
+	if rest, ok := strings.CutPrefix(dsn, memScheme); ok {
+		return newMemStorage(rest)
+	}
+	return pgstorage.NewStorageWithReplicas(dsn, pgstorage.ReplicaConfig{
+		DSNs:   splitList(cfg.DatabaseReplicas),
+		MaxLag: cfg.DatabaseReplicaLag,
+	})
+}
+
+// splitList разбор списка через запятую без пустых значений
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// withCache обёртка хранилища кэшем: общим в Redis, если задан адрес, иначе в памяти процесса.
+// Кэш процесса сбрасывается только записями этого инстанса, поэтому подходит для единственного инстанса
+func withCache(ctx context.Context, store storage.Storage, cfg *config.Config) (storage.Storage, error) {
+	if len(cfg.CacheRedisAddr) == 0 && cfg.CacheSize <= 0 {
+		return store, nil
+	}
+	if cfg.CacheTTL <= 0 {
+		return nil, errors.New("время жизни значения в кэше должно быть больше 0")
+	}
+	var cache cachestorage.Cache
+	switch {
+	case len(cfg.CacheRedisAddr) > 0:
+		redisCache, err := cachestorage.NewRedisCache(ctx, cfg.CacheRedisAddr)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка подключения к Redis: %w", err)
+		}
+		cache = redisCache
+	default:
+		logger.Log.Warnf("кэш в памяти процесса не сбрасывается при записи другими инстансами, "+
+			"при нескольких инстансах баланс может быть устаревшим до %s, для них нужен общий кэш -cache-redis", cfg.CacheTTL)
+		cache = cachestorage.NewLRU(cfg.CacheSize)
+	}
+	return cachestorage.New(store, cache, cfg.CacheTTL), nil
+}
+
+func newMemStorage(rest string) (storage.Storage, error) {
+	dir, query, _ := strings.Cut(rest, "?")
+	if len(dir) == 0 {
+		return memstorage.NewStorage()
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("неверные параметры memstorage: %w", err)
+	}
+	interval := defaultCompactInterval
+	if value := params.Get("compact_interval"); len(value) > 0 {
+		if interval, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("неверный compact_interval: %w", err)
+		}
+	}
+	return memstorage.NewPersistentStorage(dir, interval)
+}
@@ -8,7 +8,8 @@ const (
 )
 
 type Accrual struct {
-	Number  string   `json:"order"`  // Номер заказа
-	Status  string   `json:"status"` // Статус заказа
-	Accrual *float64 `json:"accrual,omitempty"`
+	Number   string   `json:"order"`  // Номер заказа
+	Status   string   `json:"status"` // Статус заказа
+	Accrual  *float64 `json:"accrual,omitempty"`
+	HTTPCode int      `json:"-"` // HTTPCode статус ответа системы расчёта, 0 - ответ получен не по HTTP
 }
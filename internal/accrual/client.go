@@ -1,13 +1,16 @@
 package accrual
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"net/http"
 )
 
 type Client interface {
-	Get(number string) (Accrual, error)
+	Get(ctx context.Context, number string) (Accrual, error)
 }
 
 func NewHTTPClient(baseURL string) Client {
@@ -18,42 +21,51 @@ type clientHTTP struct {
 	baseURL string
 }
 
-func (c clientHTTP) Get(number string) (Accrual, error) {
+func (c clientHTTP) Get(ctx context.Context, number string) (Accrual, error) {
 
 	var orderData Accrual
 
 	// Формирование URL для GET-запроса
 	url := fmt.Sprintf("%s/api/orders/%s", c.baseURL, number)
 
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return orderData, fmt.Errorf("ошибка формирования GET-запроса: %w", err)
+	}
+	// заголовки traceparent и tracestate связывают трассировку системы расчёта с опросом заказа
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
 	// Выполнение GET-запроса
-	response, err := http.Get(url)
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return orderData, fmt.Errorf("ошибка при выполнении GET-запроса: %w", err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode == http.StatusTooManyRequests {
-		return orderData, ErrTooManyRequests
+		return orderData, &StatusError{response.StatusCode, ErrTooManyRequests}
 	}
 
 	if response.StatusCode == http.StatusNoContent {
-		return orderData, ErrNotRegistered
+		return orderData, &StatusError{response.StatusCode, ErrNotRegistered}
 	}
 
 	if response.StatusCode == http.StatusInternalServerError {
-		return orderData, fmt.Errorf("ошибка запроса сервиса")
+		return orderData, &StatusError{response.StatusCode, fmt.Errorf("ошибка запроса сервиса")}
 	}
 
 	if response.StatusCode != http.StatusOK {
-		return orderData, fmt.Errorf("неизвестный статус ответа: %d - %s", response.StatusCode, response.Status)
+		return orderData, &StatusError{response.StatusCode, fmt.Errorf("неизвестный статус ответа: %d - %s", response.StatusCode, response.Status)}
 	}
 
 	// Декодирование JSON-данных в структуру Order
 	err = json.NewDecoder(response.Body).Decode(&orderData)
 
 	if err != nil {
-		return orderData, fmt.Errorf("ошибка декодирования JSON: %w", err)
+		return orderData, &StatusError{response.StatusCode, fmt.Errorf("ошибка декодирования JSON: %w", err)}
 	}
 
+	orderData.HTTPCode = response.StatusCode
+
 	return orderData, nil
 }
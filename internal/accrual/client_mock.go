@@ -1,5 +1,7 @@
 package accrual
 
+import "context"
+
 type ClientMockResponse struct {
 	Accrual Accrual // ответ
 	Error   error   // ошибка
@@ -13,7 +15,7 @@ type clientMock struct {
 	rules map[string][]ClientMockResponse //правила ответов на запросы [номерзаказа][]Ответы
 }
 
-func (c clientMock) Get(number string) (Accrual, error) {
+func (c clientMock) Get(_ context.Context, number string) (Accrual, error) {
 
 	rules, ok := c.rules[number]
 
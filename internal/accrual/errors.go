@@ -5,4 +5,23 @@ import "errors"
 var (
 	ErrNotRegistered   = errors.New("заказ не зарегистрирован в системе расчета")
 	ErrTooManyRequests = errors.New("превышено количество запросов к сервису")
+
+	ErrOrderAlreadyRegistered = errors.New("заказ уже зарегистрирован в системе расчета")
+	ErrRewardAlreadyExists    = errors.New("правило вознаграждения уже существует")
+	ErrInvalidReward          = errors.New("неверный формат правила вознаграждения")
+	ErrInvalidOrder           = errors.New("неверный формат заказа")
 )
+
+// StatusError ответ системы расчёта с неуспешным HTTP статусом
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,90 @@
+package accrual
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// unreachableThreshold количество неудачных запросов подряд, после которого система расчёта считается недоступной
+const unreachableThreshold = 5
+
+// Health состояние системы расчёта и пула воркеров для проверки готовности
+type Health struct {
+	Running             bool      // Running сервис запущен через Run
+	Workers             int       // Workers количество запущенных воркеров
+	WorkersAlive        int       // WorkersAlive количество работающих воркеров
+	GeneratorAlive      bool      // GeneratorAlive работает выборка заказов для опроса
+	Reachable           bool      // Reachable меньше unreachableThreshold неудачных запросов подряд
+	ConsecutiveFailures int       // ConsecutiveFailures количество неудачных запросов подряд
+	LastSuccess         time.Time // LastSuccess время последнего ответа системы расчёта, нулевое если ответов не было
+	LastError           string    // LastError ошибка последнего неудачного запроса
+	Limit               int       // Limit текущий лимит одновременных запросов адаптивного режима, 0 - режим отключен
+}
+
+// health счётчики состояния, изменяемые воркерами и генератором
+type health struct {
+	mu             sync.Mutex
+	workers        int
+	workersAlive   int
+	generatorAlive bool
+	failures       int
+	lastSuccess    time.Time
+	lastError      string
+}
+
+// observe учёт результата запроса: ошибки соединения и ответы 5xx считаются неудачными,
+// остальные ответы, в том числе 204 и 429, подтверждают доступность системы расчёта
+func (h *health) observe(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var statusErr *StatusError
+	if err == nil || errors.As(err, &statusErr) && statusErr.Code < http.StatusInternalServerError {
+		h.failures = 0
+		h.lastSuccess = time.Now()
+		return
+	}
+	h.failures++
+	h.lastError = err.Error()
+}
+
+func (h *health) setWorkerAlive(alive bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if alive {
+		h.workersAlive++
+	} else {
+		h.workersAlive--
+	}
+}
+
+func (h *health) setGeneratorAlive(alive bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.generatorAlive = alive
+}
+
+// Health текущее состояние, до вызова Run сервис считается не запущенным
+func (s *Service) Health() Health {
+	if s.health == nil {
+		return Health{Reachable: true}
+	}
+	h := s.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := Health{
+		Running:             true,
+		Workers:             h.workers,
+		WorkersAlive:        h.workersAlive,
+		GeneratorAlive:      h.generatorAlive,
+		Reachable:           h.failures < unreachableThreshold,
+		ConsecutiveFailures: h.failures,
+		LastSuccess:         h.lastSuccess,
+		LastError:           h.lastError,
+	}
+	if s.limiter != nil {
+		result.Limit = s.limiter.Limit()
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package accrual
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestHealth_observe(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		reachable bool
+		failures  int
+	}{
+		{"#1 no requests", nil, true, 0},
+		{"#2 connection errors below threshold", []error{errors.New("connection refused"), errors.New("connection refused")}, true, 2},
+		{"#3 connection errors reach threshold", []error{errors.New("1"), errors.New("2"), errors.New("3"), errors.New("4"), errors.New("5")}, false, 5},
+		{"#4 server errors count as failures", []error{
+			&StatusError{http.StatusInternalServerError, errors.New("1")},
+			&StatusError{http.StatusBadGateway, errors.New("2")},
+			&StatusError{http.StatusServiceUnavailable, errors.New("3")},
+			&StatusError{http.StatusInternalServerError, errors.New("4")},
+			&StatusError{http.StatusInternalServerError, errors.New("5")},
+		}, false, 5},
+		{"#5 success resets failures", []error{errors.New("1"), errors.New("2"), errors.New("3"), errors.New("4"), nil}, true, 0},
+		{"#6 too many requests and no content mean reachable", []error{
+			errors.New("1"), errors.New("2"), errors.New("3"), errors.New("4"),
+			&StatusError{http.StatusTooManyRequests, ErrTooManyRequests},
+			&StatusError{http.StatusNoContent, ErrNotRegistered},
+		}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Service{health: &health{workers: 1}}
+			for _, err := range tt.errs {
+				s.health.observe(err)
+			}
+			h := s.Health()
+			assert.True(t, h.Running)
+			assert.Equal(t, tt.reachable, h.Reachable)
+			assert.Equal(t, tt.failures, h.ConsecutiveFailures)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MaxRateLimit максимальное ограничение запросов в секунду, при большем интервал тикера меньше микросекунды
+const MaxRateLimit = 1000000
+
+// rateLimiter ограничивает количество запросов в секунду к системе расчёта
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+// newRateLimiter ограничение rps запросов в секунду, rps больше MaxRateLimit уменьшается до MaxRateLimit
+func newRateLimiter(rps int) *rateLimiter {
+	if rps > MaxRateLimit {
+		rps = MaxRateLimit
+	}
+	return &rateLimiter{ticker: time.NewTicker(time.Second / time.Duration(rps))}
+}
+
+// Wait ожидает разрешения на выполнение запроса
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.ticker.C:
+		return nil
+	}
+}
+
+func (l *rateLimiter) Stop() {
+	l.ticker.Stop()
+}
+
+// adaptiveLimiter ограничивает количество одновременных запросов к системе расчёта.
+// Лимит уменьшается вдвое при ответе 429, на единицу при превышении целевой задержки
+// и увеличивается на единицу, если ответ пришёл быстрее половины целевой задержки.
+type adaptiveLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	inUse   int
+	min     int
+	max     int
+	target  time.Duration
+	changed chan struct{}
+}
+
+func newAdaptiveLimiter(maxLimit int, target time.Duration) *adaptiveLimiter {
+	return &adaptiveLimiter{limit: maxLimit, min: 1, max: maxLimit, target: target, changed: make(chan struct{})}
+}
+
+// Acquire занимает слот, ожидая освобождения если лимит исчерпан
+func (l *adaptiveLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inUse < l.limit {
+			l.inUse++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release освобождает слот и корректирует лимит по результату запроса
+func (l *adaptiveLimiter) Release(latency time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inUse--
+
+	switch {
+	case errors.Is(err, ErrTooManyRequests):
+		l.limit /= 2
+	case err != nil:
+	case latency > l.target:
+		l.limit--
+	case latency < l.target/2:
+		l.limit++
+	}
+
+	if l.limit < l.min {
+		l.limit = l.min
+	}
+	if l.limit > l.max {
+		l.limit = l.max
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Limit текущий лимит одновременных запросов
+func (l *adaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
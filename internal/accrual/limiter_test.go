@@ -0,0 +1,69 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveLimiter_Release(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		latency time.Duration
+		err     error
+		want    int
+	}{
+		{"#1 too many requests halves limit", 8, 10 * time.Millisecond, ErrTooManyRequests, 4},
+		{"#2 slow response decreases limit", 8, time.Second, nil, 7},
+		{"#3 fast response increases limit", 4, 10 * time.Millisecond, nil, 5},
+		{"#4 limit not above max", 8, 10 * time.Millisecond, nil, 8},
+		{"#5 limit not below min", 1, 10 * time.Millisecond, ErrTooManyRequests, 1},
+		{"#6 other error keeps limit", 4, 10 * time.Millisecond, errors.New("test"), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAdaptiveLimiter(8, 500*time.Millisecond)
+			l.limit = tt.limit
+			assert.NoError(t, l.Acquire(context.Background()))
+			l.Release(tt.latency, tt.err)
+			assert.Equal(t, tt.want, l.Limit())
+		})
+	}
+}
+
+func TestAdaptiveLimiter_Acquire(t *testing.T) {
+	l := newAdaptiveLimiter(1, 500*time.Millisecond)
+	assert.NoError(t, l.Acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Acquire(ctx), context.DeadlineExceeded)
+
+	go l.Release(10*time.Millisecond, nil)
+	assert.NoError(t, l.Acquire(context.Background()))
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		rps  int
+	}{
+		{"#1 regular rate", 10},
+		{"#2 max rate", MaxRateLimit},
+		{"#3 rate above nanosecond resolution is capped", 2000000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l *rateLimiter
+			assert.NotPanics(t, func() { l = newRateLimiter(tt.rps) })
+			defer l.Stop()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			assert.NoError(t, l.Wait(ctx))
+		})
+	}
+}
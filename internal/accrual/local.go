@@ -0,0 +1,142 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/utils/luna"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	RewardTypePercent = "%"  // RewardTypePercent вознаграждение в процентах от стоимости товара
+	RewardTypePoints  = "pt" // RewardTypePoints фиксированное вознаграждение в баллах
+)
+
+// Reward правило расчёта вознаграждения для товаров, в описании которых встречается Match
+type Reward struct {
+	Match      string  `json:"match"`
+	Reward     float64 `json:"reward"`
+	RewardType string  `json:"reward_type"`
+}
+
+// Good товар в составе заказа
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+// OrderRegistration регистрация заказа с составом товаров
+type OrderRegistration struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+// LocalClient встроенная система расчёта, вычисляет начисления самостоятельно по правилам вознаграждения
+type LocalClient struct {
+	mu      sync.RWMutex
+	rewards []Reward
+	orders  map[string]Accrual
+}
+
+func NewLocalClient(rewards []Reward) (*LocalClient, error) {
+	c := &LocalClient{orders: make(map[string]Accrual)}
+	for _, reward := range rewards {
+		if err := c.AddReward(reward); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewLocalClientFromFile создание встроенной системы расчёта с правилами из json файла, при пустом пути без правил
+func NewLocalClientFromFile(path string) (*LocalClient, error) {
+	var rewards []Reward
+	if len(path) > 0 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения файла правил: %w", err)
+		}
+		if err := json.Unmarshal(data, &rewards); err != nil {
+			return nil, fmt.Errorf("ошибка разбора файла правил: %w", err)
+		}
+	}
+	return NewLocalClient(rewards)
+}
+
+// AddReward добавление правила вознаграждения
+func (c *LocalClient) AddReward(reward Reward) error {
+	if len(reward.Match) == 0 || reward.Reward < 0 {
+		return ErrInvalidReward
+	}
+	if reward.RewardType != RewardTypePercent && reward.RewardType != RewardTypePoints {
+		return ErrInvalidReward
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range c.rewards {
+		if strings.EqualFold(r.Match, reward.Match) {
+			return ErrRewardAlreadyExists
+		}
+	}
+	c.rewards = append(c.rewards, reward)
+	return nil
+}
+
+// RegisterOrder регистрация заказа и расчёт начисления по составу товаров.
+// Номер заказа проверяется алгоритмом Луна, цены товаров должны быть конечными и неотрицательными
+func (c *LocalClient) RegisterOrder(registration OrderRegistration) error {
+	if valid, err := luna.Valid(registration.Order); err != nil || !valid {
+		return ErrInvalidOrder
+	}
+	for _, good := range registration.Goods {
+		if good.Price < 0 || math.IsNaN(good.Price) || math.IsInf(good.Price, 0) {
+			return ErrInvalidOrder
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.orders[registration.Order]; ok {
+		return ErrOrderAlreadyRegistered
+	}
+
+	var sum float64
+	for _, good := range registration.Goods {
+		sum += c.calculate(good)
+	}
+
+	c.orders[registration.Order] = Accrual{Number: registration.Order, Status: StatusProcessed, Accrual: &sum}
+	return nil
+}
+
+// calculate расчёт вознаграждения за товар по первому подходящему правилу
+func (c *LocalClient) calculate(good Good) float64 {
+	description := strings.ToLower(good.Description)
+	for _, reward := range c.rewards {
+		if !strings.Contains(description, strings.ToLower(reward.Match)) {
+			continue
+		}
+		if reward.RewardType == RewardTypePercent {
+			return good.Price * reward.Reward / 100
+		}
+		return reward.Reward
+	}
+	return 0
+}
+
+func (c *LocalClient) Get(_ context.Context, number string) (Accrual, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	accrual, ok := c.orders[number]
+	if !ok {
+		return Accrual{}, ErrNotRegistered
+	}
+	return accrual, nil
+}
@@ -0,0 +1,82 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalClient_RegisterOrder(t *testing.T) {
+	client, err := NewLocalClient([]Reward{
+		{Match: "Bork", Reward: 10, RewardType: RewardTypePercent},
+		{Match: "чайник", Reward: 15, RewardType: RewardTypePoints},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		order OrderRegistration
+		want  float64
+		err   error
+	}{
+		{
+			name:  "#1 percent reward",
+			order: OrderRegistration{Order: "12345678903", Goods: []Good{{Description: "Утюг BORK", Price: 7000}}},
+			want:  700,
+		},
+		{
+			name:  "#2 points reward and no match",
+			order: OrderRegistration{Order: "2377225624", Goods: []Good{{Description: "Чайник Tefal", Price: 3000}, {Description: "Стул", Price: 1000}}},
+			want:  15,
+		},
+		{
+			name:  "#3 first matching rule",
+			order: OrderRegistration{Order: "123456789049", Goods: []Good{{Description: "Чайник Bork", Price: 5000}}},
+			want:  500,
+		},
+		{
+			name:  "#4 already registered",
+			order: OrderRegistration{Order: "12345678903"},
+			err:   ErrOrderAlreadyRegistered,
+		},
+		{
+			name:  "#5 invalid order number",
+			order: OrderRegistration{Order: "12345678904", Goods: []Good{{Description: "Утюг BORK", Price: 7000}}},
+			err:   ErrInvalidOrder,
+		},
+		{
+			name:  "#6 negative price",
+			order: OrderRegistration{Order: "79927398713", Goods: []Good{{Description: "Утюг BORK", Price: -7000}}},
+			err:   ErrInvalidOrder,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.RegisterOrder(tt.order)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := client.Get(context.Background(), tt.order.Order)
+			require.NoError(t, err)
+			assert.Equal(t, StatusProcessed, got.Status)
+			assert.InDelta(t, tt.want, *got.Accrual, 0.001)
+		})
+	}
+
+	_, err = client.Get(context.Background(), "79927398713")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+}
+
+func TestLocalClient_AddReward(t *testing.T) {
+	client, err := NewLocalClient(nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, client.AddReward(Reward{Match: "Bork", Reward: 10, RewardType: RewardTypePercent}))
+	assert.ErrorIs(t, client.AddReward(Reward{Match: "bork", Reward: 5, RewardType: RewardTypePoints}), ErrRewardAlreadyExists)
+	assert.ErrorIs(t, client.AddReward(Reward{Match: "Tefal", Reward: 5, RewardType: "x"}), ErrInvalidReward)
+	assert.ErrorIs(t, client.AddReward(Reward{Reward: 5, RewardType: RewardTypePoints}), ErrInvalidReward)
+}
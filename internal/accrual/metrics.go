@@ -0,0 +1,64 @@
+package accrual
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+	"strconv"
+)
+
+var (
+	queueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "accrual_queue_depth",
+		Help: "количество заказов, переданных воркерам и ещё не взятых в обработку",
+	})
+	workersTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "accrual_workers",
+		Help: "количество воркеров опроса системы расчёта",
+	})
+	workersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "accrual_workers_busy",
+		Help: "количество воркеров, обрабатывающих заказ, загрузка - отношение к accrual_workers",
+	})
+	clientDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "accrual_client_request_duration_seconds",
+		Help:    "длительность запросов в систему расчёта",
+		Buckets: metrics.DefaultBuckets,
+	})
+	clientResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "accrual_client_responses_total",
+		Help: "ответы системы расчёта по HTTP статусу, error - ответ не получен, local - встроенная система расчёта",
+	}, []string{"code"})
+	orderStatusTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "accrual_order_status_total",
+		Help: "количество переводов заказов в статус по ответам системы расчёта",
+	}, []string{"status"})
+	stuckOrdersTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "accrual_stuck_orders_total",
+		Help: "количество заказов, переведённых в STUCK",
+	})
+	reconciliationMismatchesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "accrual_reconciliation_mismatches_total",
+		Help: "количество расхождений, найденных сверкой начислений",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(queueDepth, workersTotal, workersBusy, clientDuration, clientResponses, orderStatusTotal,
+		stuckOrdersTotal, reconciliationMismatchesTotal)
+}
+
+// responseCode метка ответа системы расчёта для accrual_client_responses_total
+func responseCode(accrual Accrual, err error) string {
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return strconv.Itoa(statusErr.Code)
+	case err != nil:
+		return "error"
+	case accrual.HTTPCode > 0:
+		return strconv.Itoa(accrual.HTTPCode)
+	default:
+		return "local"
+	}
+}
@@ -0,0 +1,122 @@
+package accrual
+
+import (
+	"context"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"math"
+	"sync"
+	"time"
+)
+
+const defaultReconcileSample = 10
+
+// ReconciliationMismatch заказ, начисление которого расходится с текущим ответом системы расчёта
+type ReconciliationMismatch struct {
+	Number  string   `json:"number"`
+	UserID  int64    `json:"user_id"`
+	Stored  float64  `json:"stored"`
+	Status  string   `json:"status"`
+	Current *float64 `json:"current,omitempty"`
+}
+
+// ReconciliationReport результат очередной сверки
+type ReconciliationReport struct {
+	StartedAt  time.Time                `json:"started_at"`
+	FinishedAt time.Time                `json:"finished_at"`
+	Checked    int                      `json:"checked"`
+	Failed     int                      `json:"failed"`
+	Mismatches []ReconciliationMismatch `json:"mismatches"`
+}
+
+// reconciler периодическая сверка начислений случайной выборки обработанных заказов
+type reconciler struct {
+	mu     sync.RWMutex
+	report *ReconciliationReport
+}
+
+func (s *Service) reconcileLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			jobCtx := logger.With(ctx, logger.JobIDKey, newJobID())
+			report := s.Reconcile(jobCtx)
+			s.reconciler.mu.Lock()
+			s.reconciler.report = &report
+			s.reconciler.mu.Unlock()
+			s.deleteOldAccrualLogs(jobCtx)
+		}
+	}
+}
+
+// Reconcile сверка сохранённых начислений случайной выборки заказов PROCESSED с текущим ответом системы расчёта
+func (s *Service) Reconcile(ctx context.Context) ReconciliationReport {
+	report := ReconciliationReport{StartedAt: time.Now()}
+	sample := s.ReconcileSample
+	if sample <= 0 {
+		sample = defaultReconcileSample
+	}
+
+	orders, err := s.Storage.GetRandomProcessedOrders(ctx, sample)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("reconciliation GetRandomProcessedOrders error: %s", err.Error())
+	}
+
+	for _, order := range orders {
+		accrual, err := s.fetch(ctx, order.Number)
+		if err != nil {
+			logger.FromContext(ctx).Errorf("reconciliation: ошибка запроса заказа %s: %s", order.Number, err.Error())
+			report.Failed++
+			continue
+		}
+		report.Checked++
+
+		var stored float64
+		if order.Accrual != nil {
+			stored = *order.Accrual
+		}
+		var current float64
+		if accrual.Accrual != nil {
+			current = *accrual.Accrual
+		}
+		if accrual.Status == StatusProcessed && math.Abs(stored-current) < 1e-9 {
+			continue
+		}
+
+		mismatch := ReconciliationMismatch{Number: order.Number, UserID: order.UserID, Stored: stored, Status: accrual.Status, Current: accrual.Accrual}
+		report.Mismatches = append(report.Mismatches, mismatch)
+		logger.FromContext(ctx).Warnf("reconciliation: расхождение начисления заказа %s: сохранено %f, система расчёта %s %f", order.Number, stored, accrual.Status, current)
+	}
+
+	reconciliationMismatchesTotal.Add(float64(len(report.Mismatches)))
+	report.FinishedAt = time.Now()
+	return report
+}
+
+// deleteOldAccrualLogs удаление записей журнала ответов системы расчёта старше LogRetention
+func (s *Service) deleteOldAccrualLogs(ctx context.Context) {
+	if s.LogRetention <= 0 {
+		return
+	}
+	count, err := s.Storage.DeleteOldAccrualLogs(ctx, s.LogRetention)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("reconciliation DeleteOldAccrualLogs error: %s", err.Error())
+		return
+	}
+	if count > 0 {
+		logger.FromContext(ctx).Infof("reconciliation: удалено записей журнала ответов системы расчёта: %d", count)
+	}
+}
+
+// LastReconciliation результат последней сверки, nil если сверка не выполнялась или отключена
+func (s *Service) LastReconciliation() *ReconciliationReport {
+	if s.reconciler == nil {
+		return nil
+	}
+	s.reconciler.mu.RLock()
+	defer s.reconciler.mu.RUnlock()
+	return s.reconciler.report
+}
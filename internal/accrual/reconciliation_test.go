@@ -0,0 +1,67 @@
+package accrual
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestService_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	store, err := memstorage.NewStorage()
+	require.NoError(t, err)
+
+	user, err := store.RegisterUser(ctx, model.User{Name: "user"})
+	require.NoError(t, err)
+	for _, number := range []string{"12345678903", "2377225624"} {
+		require.NoError(t, store.CreateNewOrder(ctx, number, user.ID))
+		require.NoError(t, storage.CreditOrder(ctx, store, number, 100))
+	}
+
+	same, changed := float64(100), float64(150)
+	service := Service{Storage: store, Client: NewMockClient(map[string][]ClientMockResponse{
+		"12345678903": {{Accrual: Accrual{Number: "12345678903", Status: StatusProcessed, Accrual: &same, HTTPCode: http.StatusOK}}},
+		"2377225624":  {{Accrual: Accrual{Number: "2377225624", Status: StatusProcessed, Accrual: &changed, HTTPCode: http.StatusOK}}},
+	})}
+
+	report := service.Reconcile(ctx)
+	assert.Equal(t, 2, report.Checked)
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, "2377225624", report.Mismatches[0].Number)
+	assert.Equal(t, float64(100), report.Mismatches[0].Stored)
+
+	entries, err := store.GetAccrualLogsByOrder(ctx, "2377225624")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, StatusProcessed, entries[0].Status)
+	assert.Equal(t, http.StatusOK, entries[0].HTTPCode)
+}
+
+func TestService_deleteOldAccrualLogs(t *testing.T) {
+	ctx := context.Background()
+	store, err := memstorage.NewStorage()
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddAccrualLog(ctx, model.AccrualLog{Order: "12345678903", Status: StatusProcessing, HTTPCode: http.StatusOK}))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, store.AddAccrualLog(ctx, model.AccrualLog{Order: "12345678903", Status: StatusProcessed, HTTPCode: http.StatusOK}))
+
+	service := Service{Storage: store}
+	service.deleteOldAccrualLogs(ctx)
+	entries, err := store.GetAccrualLogsByOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "без времени хранения журнал не очищается")
+
+	service.LogRetention = 10 * time.Millisecond
+	service.deleteOldAccrualLogs(ctx)
+	entries, err = store.GetAccrualLogsByOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, StatusProcessed, entries[0].Status)
+}
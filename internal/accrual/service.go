@@ -2,25 +2,59 @@ package accrual
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	errs "github.com/superles/yapgofermart/internal/errors"
 	"github.com/superles/yapgofermart/internal/model"
 	"github.com/superles/yapgofermart/internal/storage"
 	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"runtime"
 	"sync"
 	"time"
 )
 
+const (
+	defaultPoolInterval  = 5 * time.Second
+	defaultTargetLatency = 500 * time.Millisecond
+)
+
 type Service struct {
-	Storage      storage.Storage
-	Client       Client
-	PoolInterval time.Duration
+	Storage           storage.Storage
+	Client            Client
+	PoolInterval      time.Duration // PoolInterval интервал выборки заказов для опроса
+	Workers           int           // Workers количество воркеров, по умолчанию по количеству процессоров
+	BatchSize         int           // BatchSize максимальное количество заказов за один интервал, 0 - без ограничений
+	RateLimit         int           // RateLimit максимальное количество запросов в секунду, 0 - без ограничений
+	Adaptive          bool          // Adaptive адаптивное изменение количества одновременных запросов
+	TargetLatency     time.Duration // TargetLatency целевая задержка ответа для адаптивного режима
+	PushDeadline      time.Duration // PushDeadline время ожидания push начисления, после которого заказ опрашивается
+	MaxAge            time.Duration // MaxAge время ожидания начисления, после которого заказ переводится в STUCK, 0 - без ограничений
+	MaxAttempts       int           // MaxAttempts количество опросов, после которого заказ переводится в STUCK, 0 - без ограничений
+	ReconcileInterval time.Duration // ReconcileInterval интервал сверки начислений обработанных заказов, 0 - сверка отключена
+	ReconcileSample   int           // ReconcileSample количество заказов в одной сверке
+	LogRetention      time.Duration // LogRetention время хранения журнала ответов системы расчёта, очистка при сверке, 0 - без ограничений
+	reconciler        *reconciler
+	rateLimiter       *rateLimiter
+	limiter           *adaptiveLimiter
+	health            *health
+}
+
+// newJobID идентификатор задания фонового воркера для поля job_id логов
+func newJobID() string {
+	return uuid.NewString()
 }
 
 func (s *Service) generator(ctx context.Context, ch chan<- model.Order) {
 	ticker := time.NewTicker(s.PoolInterval)
+	s.health.setGeneratorAlive(true)
 	defer func() {
+		s.health.setGeneratorAlive(false)
 		ticker.Stop()
+		close(ch)
 		logger.Log.Debug("stop generator ticker and close input channel")
 	}()
 	for {
@@ -28,28 +62,144 @@ func (s *Service) generator(ctx context.Context, ch chan<- model.Order) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			orders, err := s.Storage.GetAllNewAndProcessingOrders(ctx)
+			tickCtx, span := tracing.Start(logger.With(ctx, logger.JobIDKey, newJobID()), "accrual.poll")
+			s.markStuck(tickCtx)
+			orders, err := s.Storage.GetAllNewAndProcessingOrders(tickCtx)
+			tracing.End(span, err)
 			if err != nil {
-				logger.Log.Errorf("generator GetAll error: %s", err.Error())
+				logger.FromContext(tickCtx).Errorf("generator GetAll error: %s", err.Error())
 				continue
 			}
+			if s.PushDeadline > 0 {
+				orders = s.pushExpired(orders)
+			}
+			if s.BatchSize > 0 && len(orders) > s.BatchSize {
+				orders = orders[:s.BatchSize]
+			}
 			for _, order := range orders {
-				ch <- order
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- order:
+					queueDepth.Inc()
+				}
 			}
 
 		}
 	}
 }
 
+// markStuck перевод в STUCK заказов, исчерпавших лимит времени или попыток
+func (s *Service) markStuck(ctx context.Context) {
+	if s.MaxAge <= 0 && s.MaxAttempts <= 0 {
+		return
+	}
+	count, err := s.Storage.MarkStuckOrders(ctx, s.MaxAge, s.MaxAttempts)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("generator MarkStuckOrders error: %s", err.Error())
+		return
+	}
+	if count > 0 {
+		stuckOrdersTotal.Add(float64(count))
+		orderStatusTotal.WithLabelValues(model.OrderStatusStuck).Add(float64(count))
+		logger.FromContext(ctx).Warnf("заказов переведено в статус %s: %d", model.OrderStatusStuck, count)
+	}
+}
+
+// pushExpired заказы, для которых не пришло push начисление за PushDeadline
+func (s *Service) pushExpired(orders []model.Order) []model.Order {
+	expired := orders[:0]
+	for _, order := range orders {
+		if time.Since(order.UploadedAt) >= s.PushDeadline {
+			expired = append(expired, order)
+		}
+	}
+	return expired
+}
+
+// fetch запрос в систему расчёта с учётом ограничений частоты и количества одновременных запросов
+func (s *Service) fetch(ctx context.Context, number string) (Accrual, error) {
+	if s.rateLimiter != nil {
+		if err := s.rateLimiter.Wait(ctx); err != nil {
+			return Accrual{}, err
+		}
+	}
+
+	if s.limiter == nil {
+		accrual, err := s.get(ctx, number)
+		s.Audit(ctx, number, accrual, err)
+		return accrual, err
+	}
+
+	if err := s.limiter.Acquire(ctx); err != nil {
+		return Accrual{}, err
+	}
+	start := time.Now()
+	accrual, err := s.get(ctx, number)
+	s.limiter.Release(time.Since(start), err)
+	s.Audit(ctx, number, accrual, err)
+
+	return accrual, err
+}
+
+// get запрос в систему расчёта с учётом длительности и статуса ответа
+func (s *Service) get(ctx context.Context, number string) (Accrual, error) {
+	ctx, span := tracing.Start(ctx, "accrual.get", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("order.number", number)))
+	start := time.Now()
+	accrual, err := s.Client.Get(ctx, number)
+	clientDuration.Observe(time.Since(start).Seconds())
+	code := responseCode(accrual, err)
+	clientResponses.WithLabelValues(code).Inc()
+	span.SetAttributes(attribute.String("accrual.response", code), attribute.String("accrual.status", accrual.Status))
+	tracing.End(span, err)
+	if s.health != nil {
+		s.health.observe(err)
+	}
+	return accrual, err
+}
+
+// Audit запись ответа системы расчёта в журнал
+func (s *Service) Audit(ctx context.Context, number string, accrual Accrual, err error) {
+	entry := model.AccrualLog{Order: number, Status: accrual.Status, Accrual: accrual.Accrual, HTTPCode: accrual.HTTPCode}
+	if err != nil {
+		entry.Error = err.Error()
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			entry.HTTPCode = statusErr.Code
+		}
+	}
+	if err := s.Storage.AddAccrualLog(ctx, entry); err != nil {
+		logger.FromContext(ctx).Errorf("ошибка записи журнала начислений заказа %s: %s", number, err.Error())
+	}
+}
+
 func (s *Service) ProcessOrder(ctx context.Context, order model.Order, id int) error {
 
-	accrual, err := s.Client.Get(order.Number)
+	accrual, err := s.fetch(ctx, order.Number)
+
+	if s.MaxAttempts > 0 {
+		if err := s.Storage.IncrementOrderAttempts(ctx, order.Number); err != nil {
+			logger.FromContext(ctx).Errorf("woker #%d, ошибка учёта попытки заказа %s: %s", id, order.Number, err.Error())
+		}
+	}
 
 	if err != nil {
-		return fmt.Errorf("woker #%d, ошибка запроса сумы начисления: %s", id, err.Error())
+		return fmt.Errorf("woker #%d, ошибка запроса сумы начисления: %w", id, err)
 	}
 
+	if err = s.ApplyAccrual(ctx, accrual); errors.Is(err, errs.ErrOrderFinal) {
+		logger.FromContext(ctx).Infof("woker #%d, запоздавший ответ пропущен: %s", id, err.Error())
+	} else if err != nil {
+		logger.FromContext(ctx).Errorf("woker #%d, %s", id, err.Error())
+	}
+	return nil
+}
+
+// ApplyAccrual перевод ответа системы расчёта в статус заказа и начисление баллов пользователю.
+// Для заказа в конечном статусе возвращается ошибка errs.ErrOrderFinal, заказ не меняется
+func (s *Service) ApplyAccrual(ctx context.Context, accrual Accrual) error {
 	var status string
+	var err error
 
 	switch accrual.Status {
 	case StatusRegistered, StatusProcessing:
@@ -61,19 +211,24 @@ func (s *Service) ProcessOrder(ctx context.Context, order model.Order, id int) e
 	case StatusProcessed:
 		status = model.OrderStatusProcessed
 		if accrual.Accrual != nil && *accrual.Accrual > 0 {
-			err = s.Storage.SetOrderProcessedAndUserBalance(ctx, accrual.Number, *accrual.Accrual)
+			err = storage.CreditOrder(ctx, s.Storage, accrual.Number, *accrual.Accrual)
 		} else {
 			err = s.Storage.UpdateOrderStatus(ctx, accrual.Number, status)
 		}
+	default:
+		return fmt.Errorf("неизвестный статус %s заказа %s", accrual.Status, accrual.Number)
 	}
 
 	if err != nil {
-		logger.Log.Errorf("woker #%d, ошибка установки статуса %s заказа %s: %s", id, status, accrual.Number, err.Error())
+		return fmt.Errorf("ошибка установки статуса %s заказа %s: %w", status, accrual.Number, err)
 	}
+	orderStatusTotal.WithLabelValues(status).Inc()
 	return nil
 }
 
 func (s *Service) worker(id int, ctx context.Context, input <-chan model.Order) {
+	s.health.setWorkerAlive(true)
+	defer s.health.setWorkerAlive(false)
 	for {
 		select {
 		case <-ctx.Done():
@@ -81,35 +236,69 @@ func (s *Service) worker(id int, ctx context.Context, input <-chan model.Order)
 			return // Выход из горутины при отмене контекста
 		case order, ok := <-input:
 			if !ok {
-				logger.Log.Error("input channel closed")
+				logger.Log.Debugf("worker %d finished, input channel closed", id)
 				return
 			}
+			queueDepth.Dec()
+			workersBusy.Inc()
 
-			if err := s.ProcessOrder(ctx, order, id); err != nil {
-				logger.Log.Error(err.Error())
+			// у каждого задания свой job_id и трассировка, их получают логи и span воркера, системы расчёта и хранилища
+			jobCtx, span := tracing.Start(ctx, "accrual.job", trace.WithNewRoot(), trace.WithAttributes(attribute.Int("worker", id), attribute.String("order.number", order.Number)))
+			jobCtx = logger.With(jobCtx, logger.JobIDKey, newJobID(), "worker", id, "order", order.Number)
+			if traceID := tracing.TraceID(span); len(traceID) > 0 {
+				jobCtx = logger.With(jobCtx, logger.TraceIDKey, traceID)
+			}
+			err := s.ProcessOrder(jobCtx, order, id)
+			if err != nil {
+				logger.FromContext(jobCtx).Error(err.Error())
 			}
+			tracing.End(span, err)
+			workersBusy.Dec()
 		}
 	}
 }
 
 func (s *Service) Run(ctx context.Context) {
 	var wg sync.WaitGroup
-	rateLimit := runtime.GOMAXPROCS(0)
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 	if s.PoolInterval == 0 {
-		s.PoolInterval = 5 * time.Second
+		s.PoolInterval = defaultPoolInterval
+	}
+	if s.RateLimit > 0 {
+		s.rateLimiter = newRateLimiter(s.RateLimit)
+	}
+	if s.Adaptive {
+		if s.TargetLatency == 0 {
+			s.TargetLatency = defaultTargetLatency
+		}
+		s.limiter = newAdaptiveLimiter(workers, s.TargetLatency)
 	}
-	requestChan := make(chan model.Order, rateLimit)
+	workersTotal.Set(float64(workers))
+	s.health = &health{workers: workers}
+	logger.Log.Debugf("accrual service: workers %d, interval %s, batch %d, rate limit %d, adaptive %t", workers, s.PoolInterval, s.BatchSize, s.RateLimit, s.Adaptive)
+	requestChan := make(chan model.Order, workers)
 	go s.generator(ctx, requestChan)
-	for i := 1; i <= rateLimit; i++ {
+	if s.ReconcileInterval > 0 {
+		s.reconciler = &reconciler{}
+		go s.reconcileLoop(ctx)
+	} else if s.LogRetention > 0 {
+		logger.Log.Warnf("журнал ответов системы расчёта очищается при сверке, а сверка отключена, время хранения %s не применяется", s.LogRetention)
+	}
+	wg.Add(workers)
+	for i := 1; i <= workers; i++ {
 		go func(workerID int) {
 			defer wg.Done()
 			s.worker(workerID, ctx, requestChan)
 		}(i)
 	}
-	wg.Add(rateLimit)
 	go func() {
 		wg.Wait()
-		logger.Log.Debug("free all channels")
-		close(requestChan)
+		logger.Log.Debug("all workers finished")
+		if s.rateLimiter != nil {
+			s.rateLimiter.Stop()
+		}
 	}()
 }
@@ -0,0 +1,152 @@
+package accrualsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/valyala/fasthttp"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Step состояние заказа, которое отдаёт симулятор на очередной запрос
+type Step struct {
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+// Config настройки симулятора
+type Config struct {
+	Script           map[string][]Step // Script сценарии ответов по номерам заказов, последний шаг повторяется
+	Random           bool              // Random генерировать случайный сценарий для заказов, которых нет в Script
+	InvalidRate      float64           // InvalidRate вероятность статуса INVALID для случайного сценария
+	MaxAccrual       float64           // MaxAccrual максимальное начисление для случайного сценария
+	MinLatency       time.Duration     // MinLatency минимальная задержка ответа
+	MaxLatency       time.Duration     // MaxLatency максимальная задержка ответа
+	BurstRate        float64           // BurstRate вероятность начала серии ответов 429 на запрос
+	BurstDuration    time.Duration     // BurstDuration длительность серии ответов 429
+	ErrorRate        float64           // ErrorRate вероятность ответа 500
+	Seed             int64             // Seed начальное значение генератора случайных чисел
+	MaxRequestPerMin int               // MaxRequestPerMin значение для текста ответа 429
+}
+
+// Simulator симулятор системы расчёта начислений, реализует GET /api/orders/{number}
+type Simulator struct {
+	cfg        Config
+	mu         sync.Mutex
+	rnd        *rand.Rand
+	orders     map[string][]Step
+	burstUntil time.Time
+}
+
+func New(cfg Config) *Simulator {
+	orders := make(map[string][]Step, len(cfg.Script))
+	for number, steps := range cfg.Script {
+		orders[number] = append([]Step(nil), steps...)
+	}
+	if cfg.MaxRequestPerMin == 0 {
+		cfg.MaxRequestPerMin = 60
+	}
+	return &Simulator{cfg: cfg, rnd: rand.New(rand.NewSource(cfg.Seed)), orders: orders}
+}
+
+// LoadScript чтение сценариев из json файла формата {"номер": [{"status": "...", "accrual": 1}]}
+func LoadScript(path string) (map[string][]Step, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла сценариев: %w", err)
+	}
+	var script map[string][]Step
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("ошибка разбора файла сценариев: %w", err)
+	}
+	return script, nil
+}
+
+// randomSteps генерация случайного сценария REGISTERED -> PROCESSING -> PROCESSED|INVALID
+func (s *Simulator) randomSteps() []Step {
+	steps := []Step{{Status: accrual.StatusRegistered}}
+	for i := s.rnd.Intn(3); i >= 0; i-- {
+		steps = append(steps, Step{Status: accrual.StatusProcessing})
+	}
+	if s.rnd.Float64() < s.cfg.InvalidRate {
+		return append(steps, Step{Status: accrual.StatusInvalid})
+	}
+	sum := float64(s.rnd.Intn(int(s.cfg.MaxAccrual*100)+1)) / 100
+	return append(steps, Step{Status: accrual.StatusProcessed, Accrual: &sum})
+}
+
+// next следующий шаг сценария заказа, false если заказ не зарегистрирован
+func (s *Simulator) next(number string) (Step, bool) {
+	steps, ok := s.orders[number]
+	if !ok {
+		if !s.cfg.Random {
+			return Step{}, false
+		}
+		steps = s.randomSteps()
+	}
+	if len(steps) == 0 {
+		return Step{}, false
+	}
+	step := steps[0]
+	if len(steps) > 1 {
+		steps = steps[1:]
+	}
+	s.orders[number] = steps
+	return step, true
+}
+
+func (s *Simulator) latency() time.Duration {
+	if s.cfg.MaxLatency <= s.cfg.MinLatency {
+		return s.cfg.MinLatency
+	}
+	return s.cfg.MinLatency + time.Duration(s.rnd.Int63n(int64(s.cfg.MaxLatency-s.cfg.MinLatency)))
+}
+
+// OrderHandler обработчик GET /api/orders/{number}
+func (s *Simulator) OrderHandler(ctx *fasthttp.RequestCtx) {
+	number, _ := ctx.UserValue("number").(string)
+
+	s.mu.Lock()
+	delay := s.latency()
+	now := time.Now()
+	if now.Before(s.burstUntil) || s.rnd.Float64() < s.cfg.BurstRate {
+		if !now.Before(s.burstUntil) {
+			s.burstUntil = now.Add(s.cfg.BurstDuration)
+		}
+		retryAfter := int(math.Ceil(s.burstUntil.Sub(now).Seconds()))
+		s.mu.Unlock()
+		time.Sleep(delay)
+		ctx.Error(fmt.Sprintf("No more than %d requests per minute allowed", s.cfg.MaxRequestPerMin), fasthttp.StatusTooManyRequests)
+		ctx.Response.Header.Set("Retry-After", strconv.Itoa(retryAfter))
+		return
+	}
+	if s.rnd.Float64() < s.cfg.ErrorRate {
+		s.mu.Unlock()
+		time.Sleep(delay)
+		ctx.Error("internal server error", fasthttp.StatusInternalServerError)
+		return
+	}
+	step, ok := s.next(number)
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+
+	if !ok {
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+		return
+	}
+
+	data, err := json.Marshal(accrual.Accrual{Number: number, Status: step.Status, Accrual: step.Accrual})
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.Set("Content-Type", "application/json")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(data)
+}
@@ -0,0 +1,61 @@
+package accrualsim
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/valyala/fasthttp"
+	"testing"
+	"time"
+)
+
+func request(s *Simulator, number string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("number", number)
+	s.OrderHandler(ctx)
+	return ctx
+}
+
+func TestSimulator_Script(t *testing.T) {
+	sum := float64(500)
+	s := New(Config{Script: map[string][]Step{
+		"12345678903": {
+			{Status: accrual.StatusRegistered},
+			{Status: accrual.StatusProcessed, Accrual: &sum},
+		},
+	}})
+
+	want := []string{accrual.StatusRegistered, accrual.StatusProcessed, accrual.StatusProcessed}
+	for _, status := range want {
+		ctx := request(s, "12345678903")
+		require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+		var got accrual.Accrual
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+		assert.Equal(t, status, got.Status)
+	}
+
+	assert.Equal(t, fasthttp.StatusNoContent, request(s, "2377225624").Response.StatusCode())
+}
+
+func TestSimulator_Random(t *testing.T) {
+	s := New(Config{Random: true, MaxAccrual: 100, Seed: 1})
+
+	var got accrual.Accrual
+	for i := 0; i < 10 && got.Status != accrual.StatusProcessed && got.Status != accrual.StatusInvalid; i++ {
+		ctx := request(s, "12345678903")
+		require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+	}
+	assert.Contains(t, []string{accrual.StatusProcessed, accrual.StatusInvalid}, got.Status)
+}
+
+func TestSimulator_Failures(t *testing.T) {
+	s := New(Config{BurstRate: 1, BurstDuration: 2 * time.Second})
+	ctx := request(s, "12345678903")
+	assert.Equal(t, fasthttp.StatusTooManyRequests, ctx.Response.StatusCode())
+	assert.Equal(t, "2", string(ctx.Response.Header.Peek("Retry-After")))
+
+	s = New(Config{ErrorRate: 1})
+	assert.Equal(t, fasthttp.StatusInternalServerError, request(s, "12345678903").Response.StatusCode())
+}
@@ -4,6 +4,12 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	AccrualModeHTTP  = "http"  // AccrualModeHTTP внешняя система расчёта по адресу AccrualSystemAddress
+	AccrualModeLocal = "local" // AccrualModeLocal встроенная система расчёта по правилам из AccrualRulesFile
 )
 
 type Config struct {
@@ -13,6 +19,41 @@ type Config struct {
 	DatabaseDsn          string `env:"DATABASE_URI"`
 	SecretKey            string `env:"KEY"`
 	SecretKeyBytes       []byte
+	AccrualWorkers       int           `env:"ACCRUAL_WORKERS"`       // AccrualWorkers количество воркеров опроса системы расчёта
+	AccrualPollInterval  time.Duration `env:"ACCRUAL_POLL_INTERVAL"` // AccrualPollInterval интервал выборки заказов для опроса
+	AccrualBatchSize     int           `env:"ACCRUAL_BATCH_SIZE"`    // AccrualBatchSize максимальное количество заказов за один интервал, 0 - без ограничений
+	AccrualRateLimit     int           `env:"ACCRUAL_RATE_LIMIT"`    // AccrualRateLimit максимальное количество запросов в секунду, 0 - без ограничений
+	AccrualAdaptive      bool          `env:"ACCRUAL_ADAPTIVE"`      // AccrualAdaptive адаптивное изменение количества одновременных запросов
+	AccrualMode          string        `env:"ACCRUAL_MODE"`          // AccrualMode режим системы расчёта: http или local
+	AccrualRulesFile     string        `env:"ACCRUAL_RULES_FILE"`    // AccrualRulesFile json файл правил вознаграждения встроенной системы расчёта
+	AccrualPushKey       string        `env:"ACCRUAL_PUSH_KEY"`      // AccrualPushKey ключ HMAC подписи push начислений, пустой - push отключен
+	AccrualPushDeadline  time.Duration `env:"ACCRUAL_PUSH_DEADLINE"` // AccrualPushDeadline время ожидания push начисления до опроса заказа
+	AccrualMaxAge        time.Duration `env:"ACCRUAL_MAX_AGE"`       // AccrualMaxAge время ожидания начисления до перевода заказа в STUCK, 0 - без ограничений
+	AccrualMaxAttempts   int           `env:"ACCRUAL_MAX_ATTEMPTS"`  // AccrualMaxAttempts количество опросов до перевода заказа в STUCK, 0 - без ограничений
+	ReconcileInterval    time.Duration `env:"RECONCILE_INTERVAL"`    // ReconcileInterval интервал сверки начислений, 0 - сверка отключена
+	ReconcileSample      int           `env:"RECONCILE_SAMPLE"`      // ReconcileSample количество заказов в одной сверке
+	AccrualLogRetention  time.Duration `env:"ACCRUAL_LOG_RETENTION"` // AccrualLogRetention время хранения журнала ответов системы расчёта, очистка при сверке, 0 - без ограничений
+	CacheSize            int           `env:"CACHE_SIZE"`            // CacheSize количество пользователей в кэше процесса для единственного инстанса, 0 - кэш отключен
+	CacheTTL             time.Duration `env:"CACHE_TTL"`             // CacheTTL время жизни значения в кэше, больше 0
+	CacheRedisAddr       string        `env:"CACHE_REDIS_ADDR"`      // CacheRedisAddr адрес сервера Redis для общего кэша вместо кэша процесса
+
+	DatabaseReplicas   string        `env:"DATABASE_REPLICA_URIS"`    // DatabaseReplicas строки подключения к репликам Postgres через запятую
+	DatabaseReplicaLag time.Duration `env:"DATABASE_REPLICA_MAX_LAG"` // DatabaseReplicaLag максимальное отставание реплики для чтения
+	StorageSlowQuery   time.Duration `env:"STORAGE_SLOW_THRESHOLD"`   // StorageSlowQuery порог длительности вызова хранилища для записи в лог, 0 - не логировать
+
+	DefaultLanguage          string `env:"DEFAULT_LANGUAGE"`           // DefaultLanguage язык сообщений API, если Accept-Language не содержит поддерживаемого
+	OpenAPIValidateResponses bool   `env:"OPENAPI_VALIDATE_RESPONSES"` // OpenAPIValidateResponses проверка ответов по api/api.yml, для тестов
+
+	GRPCEndpoint      string        `env:"GRPC_ADDRESS"`        // GRPCEndpoint адрес gRPC сервера, пустой - gRPC отключен
+	GRPCWatchInterval time.Duration `env:"GRPC_WATCH_INTERVAL"` // GRPCWatchInterval интервал проверки статусов заказов для WatchOrders
+
+	MetricsEndpoint string `env:"METRICS_ADDRESS"` // MetricsEndpoint адрес служебного сервера /metrics, пустой - метрики не отдаются
+
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY"` // ShutdownDelay время между переводом /readyz в 503 и остановкой приёма запросов
+
+	TracingExporter string `env:"TRACING_EXPORTER"` // TracingExporter экспорт span: none, otlp или stdout
+	TracingEndpoint string `env:"TRACING_ENDPOINT"` // TracingEndpoint адрес коллектора OTLP/gRPC, пустой - из OTEL_EXPORTER_OTLP_ENDPOINT
+	TracingFile     string `env:"TRACING_FILE"`     // TracingFile файл для экспорта stdout, пустой - stdout процесса
 }
 
 var (
@@ -77,6 +118,24 @@ func New() (*Config, error) {
 			instance.DatabaseDsn = flagConfig.DatabaseDsn
 		}
 
+		if len(envConfig.DatabaseReplicas) > 0 {
+			instance.DatabaseReplicas = envConfig.DatabaseReplicas
+		} else {
+			instance.DatabaseReplicas = flagConfig.DatabaseReplicas
+		}
+
+		if envConfig.DatabaseReplicaLag > 0 {
+			instance.DatabaseReplicaLag = envConfig.DatabaseReplicaLag
+		} else {
+			instance.DatabaseReplicaLag = flagConfig.DatabaseReplicaLag
+		}
+
+		if envConfig.StorageSlowQuery > 0 {
+			instance.StorageSlowQuery = envConfig.StorageSlowQuery
+		} else {
+			instance.StorageSlowQuery = flagConfig.StorageSlowQuery
+		}
+
 		if len(envConfig.SecretKey) > 0 {
 			instance.SecretKey = envConfig.SecretKey
 			instance.SecretKeyBytes = []byte(instance.SecretKey)
@@ -86,6 +145,154 @@ func New() (*Config, error) {
 			instance.SecretKey = flagConfig.SecretKey
 			instance.SecretKeyBytes = []byte(instance.SecretKey)
 		}
+
+		if envConfig.AccrualWorkers > 0 {
+			instance.AccrualWorkers = envConfig.AccrualWorkers
+		} else {
+			instance.AccrualWorkers = flagConfig.AccrualWorkers
+		}
+
+		if envConfig.AccrualPollInterval > 0 {
+			instance.AccrualPollInterval = envConfig.AccrualPollInterval
+		} else {
+			instance.AccrualPollInterval = flagConfig.AccrualPollInterval
+		}
+
+		if envConfig.AccrualBatchSize > 0 {
+			instance.AccrualBatchSize = envConfig.AccrualBatchSize
+		} else {
+			instance.AccrualBatchSize = flagConfig.AccrualBatchSize
+		}
+
+		if envConfig.AccrualRateLimit > 0 {
+			instance.AccrualRateLimit = envConfig.AccrualRateLimit
+		} else {
+			instance.AccrualRateLimit = flagConfig.AccrualRateLimit
+		}
+
+		instance.AccrualAdaptive = envConfig.AccrualAdaptive || flagConfig.AccrualAdaptive
+
+		if len(envConfig.AccrualMode) > 0 {
+			instance.AccrualMode = envConfig.AccrualMode
+		} else {
+			instance.AccrualMode = flagConfig.AccrualMode
+		}
+
+		if len(envConfig.AccrualRulesFile) > 0 {
+			instance.AccrualRulesFile = envConfig.AccrualRulesFile
+		} else {
+			instance.AccrualRulesFile = flagConfig.AccrualRulesFile
+		}
+
+		if len(envConfig.AccrualPushKey) > 0 {
+			instance.AccrualPushKey = envConfig.AccrualPushKey
+		} else {
+			instance.AccrualPushKey = flagConfig.AccrualPushKey
+		}
+
+		if envConfig.AccrualPushDeadline > 0 {
+			instance.AccrualPushDeadline = envConfig.AccrualPushDeadline
+		} else {
+			instance.AccrualPushDeadline = flagConfig.AccrualPushDeadline
+		}
+
+		if envConfig.AccrualMaxAge > 0 {
+			instance.AccrualMaxAge = envConfig.AccrualMaxAge
+		} else {
+			instance.AccrualMaxAge = flagConfig.AccrualMaxAge
+		}
+
+		if envConfig.AccrualMaxAttempts > 0 {
+			instance.AccrualMaxAttempts = envConfig.AccrualMaxAttempts
+		} else {
+			instance.AccrualMaxAttempts = flagConfig.AccrualMaxAttempts
+		}
+
+		if envConfig.ReconcileInterval > 0 {
+			instance.ReconcileInterval = envConfig.ReconcileInterval
+		} else {
+			instance.ReconcileInterval = flagConfig.ReconcileInterval
+		}
+
+		if envConfig.ReconcileSample > 0 {
+			instance.ReconcileSample = envConfig.ReconcileSample
+		} else {
+			instance.ReconcileSample = flagConfig.ReconcileSample
+		}
+
+		if envConfig.AccrualLogRetention > 0 {
+			instance.AccrualLogRetention = envConfig.AccrualLogRetention
+		} else {
+			instance.AccrualLogRetention = flagConfig.AccrualLogRetention
+		}
+
+		if envConfig.CacheSize > 0 {
+			instance.CacheSize = envConfig.CacheSize
+		} else {
+			instance.CacheSize = flagConfig.CacheSize
+		}
+
+		if envConfig.CacheTTL > 0 {
+			instance.CacheTTL = envConfig.CacheTTL
+		} else {
+			instance.CacheTTL = flagConfig.CacheTTL
+		}
+
+		if len(envConfig.CacheRedisAddr) > 0 {
+			instance.CacheRedisAddr = envConfig.CacheRedisAddr
+		} else {
+			instance.CacheRedisAddr = flagConfig.CacheRedisAddr
+		}
+
+		instance.OpenAPIValidateResponses = envConfig.OpenAPIValidateResponses || flagConfig.OpenAPIValidateResponses
+
+		if len(envConfig.DefaultLanguage) > 0 {
+			instance.DefaultLanguage = envConfig.DefaultLanguage
+		} else {
+			instance.DefaultLanguage = flagConfig.DefaultLanguage
+		}
+
+		if len(envConfig.GRPCEndpoint) > 0 {
+			instance.GRPCEndpoint = envConfig.GRPCEndpoint
+		} else {
+			instance.GRPCEndpoint = flagConfig.GRPCEndpoint
+		}
+
+		if envConfig.GRPCWatchInterval > 0 {
+			instance.GRPCWatchInterval = envConfig.GRPCWatchInterval
+		} else {
+			instance.GRPCWatchInterval = flagConfig.GRPCWatchInterval
+		}
+
+		if len(envConfig.MetricsEndpoint) > 0 {
+			instance.MetricsEndpoint = envConfig.MetricsEndpoint
+		} else {
+			instance.MetricsEndpoint = flagConfig.MetricsEndpoint
+		}
+
+		if envConfig.ShutdownDelay > 0 {
+			instance.ShutdownDelay = envConfig.ShutdownDelay
+		} else {
+			instance.ShutdownDelay = flagConfig.ShutdownDelay
+		}
+
+		if len(envConfig.TracingExporter) > 0 {
+			instance.TracingExporter = envConfig.TracingExporter
+		} else {
+			instance.TracingExporter = flagConfig.TracingExporter
+		}
+
+		if len(envConfig.TracingEndpoint) > 0 {
+			instance.TracingEndpoint = envConfig.TracingEndpoint
+		} else {
+			instance.TracingEndpoint = flagConfig.TracingEndpoint
+		}
+
+		if len(envConfig.TracingFile) > 0 {
+			instance.TracingFile = envConfig.TracingFile
+		} else {
+			instance.TracingFile = flagConfig.TracingFile
+		}
 	})
 
 	return &instance, err
@@ -0,0 +1,280 @@
+package dump
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Файлы выгрузки в CSV, загружаются в этом порядке
+const (
+	UsersFile       = "users.csv"
+	OrdersFile      = "orders.csv"
+	WithdrawalsFile = "withdrawals.csv"
+)
+
+var (
+	userHeader       = []string{"id", "name", "password_hash", "role", "balance", "withdrawn"}
+	orderHeader      = []string{"number", "user_id", "status", "accrual", "uploaded_at", "attempts"}
+	withdrawalHeader = []string{"order", "user_id", "sum", "processed_at"}
+)
+
+type csvFile struct {
+	file *os.File
+	w    *csv.Writer
+}
+
+// CSVEncoder выгрузка в каталог с файлами users.csv, orders.csv и withdrawals.csv
+type CSVEncoder struct {
+	files map[string]*csvFile
+}
+
+func NewCSVEncoder(dir string) (*CSVEncoder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	e := &CSVEncoder{files: make(map[string]*csvFile)}
+	for _, typ := range []string{TypeUser, TypeOrder, TypeWithdrawal} {
+		name, header := csvLayout(typ)
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			_ = e.Close()
+			return nil, err
+		}
+		f := &csvFile{file: file, w: csv.NewWriter(file)}
+		e.files[typ] = f
+		if err := f.w.Write(header); err != nil {
+			_ = e.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *CSVEncoder) Encode(record Record) error {
+	f, ok := e.files[record.Type]
+	if !ok {
+		return fmt.Errorf("неизвестная запись выгрузки типа %q", record.Type)
+	}
+	switch {
+	case record.User != nil:
+		u := record.User
+		return f.w.Write([]string{formatInt(u.ID), u.Name, u.PasswordHash, u.Role, formatFloat(u.Balance), formatFloat(u.Withdrawn)})
+	case record.Order != nil:
+		o := record.Order
+		accrual := ""
+		if o.Accrual != nil {
+			accrual = formatFloat(*o.Accrual)
+		}
+		return f.w.Write([]string{o.Number, formatInt(o.UserID), o.Status, accrual, formatTime(o.UploadedAt), strconv.Itoa(o.Attempts)})
+	case record.Withdrawal != nil:
+		w := record.Withdrawal
+		return f.w.Write([]string{w.Order, formatInt(w.UserID), formatFloat(w.Sum), formatTime(w.ProcessedAt)})
+	default:
+		return fmt.Errorf("пустая запись выгрузки типа %q", record.Type)
+	}
+}
+
+// Close запись буферов и закрытие файлов
+func (e *CSVEncoder) Close() error {
+	var err error
+	for _, f := range e.files {
+		f.w.Flush()
+		if flushErr := f.w.Error(); err == nil {
+			err = flushErr
+		}
+		if closeErr := f.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// CSVDecoder чтение выгрузки из каталога, отсутствующий файл считается пустым
+type CSVDecoder struct {
+	dir     string
+	pending []string // pending типы записей, файлы которых ещё не открывались
+	typ     string
+	file    *os.File
+	r       *csv.Reader
+}
+
+func NewCSVDecoder(dir string) (*CSVDecoder, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s не является каталогом", dir)
+	}
+	return &CSVDecoder{dir: dir, pending: []string{TypeUser, TypeOrder, TypeWithdrawal}}, nil
+}
+
+func (d *CSVDecoder) Decode() (Record, error) {
+	for {
+		if d.r == nil {
+			if err := d.openNext(); err != nil {
+				return Record{}, err
+			}
+			continue
+		}
+
+		row, err := d.r.Read()
+		if errors.Is(err, io.EOF) {
+			_ = d.file.Close()
+			d.file, d.r = nil, nil
+			continue
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		return parseRow(d.typ, row)
+	}
+}
+
+// openNext открытие следующего существующего файла с проверкой заголовка, io.EOF если файлов больше нет
+func (d *CSVDecoder) openNext() error {
+	for len(d.pending) > 0 {
+		typ := d.pending[0]
+		d.pending = d.pending[1:]
+
+		name, header := csvLayout(typ)
+		file, err := os.Open(filepath.Join(d.dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r := csv.NewReader(file)
+		r.FieldsPerRecord = len(header)
+		got, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			_ = file.Close()
+			continue
+		}
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for i := range header {
+			if got[i] != header[i] {
+				_ = file.Close()
+				return fmt.Errorf("%s: неверный заголовок, ожидается %v", name, header)
+			}
+		}
+		d.typ, d.file, d.r = typ, file, r
+		return nil
+	}
+	return io.EOF
+}
+
+func (d *CSVDecoder) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file, d.r = nil, nil
+	return err
+}
+
+func csvLayout(typ string) (string, []string) {
+	switch typ {
+	case TypeUser:
+		return UsersFile, userHeader
+	case TypeOrder:
+		return OrdersFile, orderHeader
+	default:
+		return WithdrawalsFile, withdrawalHeader
+	}
+}
+
+func parseRow(typ string, row []string) (Record, error) {
+	var p rowParser
+	switch typ {
+	case TypeUser:
+		u := &User{
+			ID:           p.int(row[0]),
+			Name:         row[1],
+			PasswordHash: row[2],
+			Role:         row[3],
+			Balance:      p.float(row[4]),
+			Withdrawn:    p.float(row[5]),
+		}
+		return Record{Type: typ, User: u}, p.wrap(UsersFile)
+	case TypeOrder:
+		o := &Order{
+			Number:     row[0],
+			UserID:     p.int(row[1]),
+			Status:     row[2],
+			UploadedAt: p.time(row[4]),
+			Attempts:   int(p.int(row[5])),
+		}
+		if len(row[3]) > 0 {
+			accrual := p.float(row[3])
+			o.Accrual = &accrual
+		}
+		return Record{Type: typ, Order: o}, p.wrap(OrdersFile)
+	default:
+		w := &Withdrawal{
+			Order:       row[0],
+			UserID:      p.int(row[1]),
+			Sum:         p.float(row[2]),
+			ProcessedAt: p.time(row[3]),
+		}
+		return Record{Type: typ, Withdrawal: w}, p.wrap(WithdrawalsFile)
+	}
+}
+
+// rowParser разбор полей строки с запоминанием первой ошибки
+type rowParser struct {
+	err error
+}
+
+func (p *rowParser) int(value string) int64 {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+func (p *rowParser) float(value string) float64 {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+func (p *rowParser) time(value string) time.Time {
+	v, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+func (p *rowParser) wrap(name string) error {
+	if p.err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", name, p.err)
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
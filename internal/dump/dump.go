@@ -0,0 +1,209 @@
+// Package dump выгрузка и загрузка данных хранилища в переносимом формате
+// для переноса между бэкендами и резервного копирования
+package dump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"io"
+	"time"
+)
+
+const (
+	TypeUser       = "user"
+	TypeOrder      = "order"
+	TypeWithdrawal = "withdrawal"
+)
+
+// User пользователь в выгрузке
+type User struct {
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	PasswordHash string  `json:"password_hash"`
+	Role         string  `json:"role"`
+	Balance      float64 `json:"balance"`
+	Withdrawn    float64 `json:"withdrawn"`
+}
+
+// Order заказ в выгрузке
+type Order struct {
+	Number     string    `json:"number"`
+	UserID     int64     `json:"user_id"`
+	Status     string    `json:"status"`
+	Accrual    *float64  `json:"accrual,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Attempts   int       `json:"attempts"`
+}
+
+// Withdrawal списание в выгрузке
+type Withdrawal struct {
+	Order       string    `json:"order"`
+	UserID      int64     `json:"user_id"`
+	Sum         float64   `json:"sum"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// Record запись выгрузки, заполнено одно поле по Type
+type Record struct {
+	Type       string      `json:"type"`
+	User       *User       `json:"user,omitempty"`
+	Order      *Order      `json:"order,omitempty"`
+	Withdrawal *Withdrawal `json:"withdrawal,omitempty"`
+}
+
+// Encoder запись выгрузки. Записи приходят в порядке пользователи, заказы, списания
+type Encoder interface {
+	Encode(record Record) error
+	Close() error
+}
+
+// Decoder чтение выгрузки, io.EOF по окончании записей.
+// Пользователи должны идти раньше их заказов и списаний
+type Decoder interface {
+	Decode() (Record, error)
+	Close() error
+}
+
+// Counts количество перенесённых записей
+type Counts struct {
+	Users       int
+	Orders      int
+	Withdrawals int
+	Skipped     int // Skipped количество записей, уже бывших в хранилище при загрузке с пропуском
+}
+
+func (c Counts) String() string {
+	result := fmt.Sprintf("пользователей: %d, заказов: %d, списаний: %d", c.Users, c.Orders, c.Withdrawals)
+	if c.Skipped > 0 {
+		result += fmt.Sprintf(", пропущено: %d", c.Skipped)
+	}
+	return result
+}
+
+// Export выгрузка всех пользователей, заказов и списаний хранилища в enc
+func Export(ctx context.Context, s storage.Storage, enc Encoder) (Counts, error) {
+	var counts Counts
+	err := s.ExportUsers(ctx, func(user model.User) error {
+		counts.Users++
+		return enc.Encode(Record{Type: TypeUser, User: &User{
+			ID:           user.ID,
+			Name:         user.Name,
+			PasswordHash: user.PasswordHash,
+			Role:         user.Role,
+			Balance:      user.Balance,
+			Withdrawn:    user.Withdrawn,
+		}})
+	})
+	if err != nil {
+		return counts, fmt.Errorf("ошибка выгрузки пользователей: %w", err)
+	}
+
+	err = s.ExportOrders(ctx, func(order model.Order) error {
+		counts.Orders++
+		return enc.Encode(Record{Type: TypeOrder, Order: &Order{
+			Number:     order.Number,
+			UserID:     order.UserID,
+			Status:     order.Status,
+			Accrual:    order.Accrual,
+			UploadedAt: order.UploadedAt,
+			Attempts:   order.Attempts,
+		}})
+	})
+	if err != nil {
+		return counts, fmt.Errorf("ошибка выгрузки заказов: %w", err)
+	}
+
+	err = s.ExportWithdrawals(ctx, func(withdrawal model.Withdrawal) error {
+		counts.Withdrawals++
+		return enc.Encode(Record{Type: TypeWithdrawal, Withdrawal: &Withdrawal{
+			Order:       withdrawal.Order,
+			UserID:      withdrawal.UserID,
+			Sum:         withdrawal.Sum,
+			ProcessedAt: withdrawal.ProcessedAt,
+		}})
+	})
+	if err != nil {
+		return counts, fmt.Errorf("ошибка выгрузки списаний: %w", err)
+	}
+	return counts, nil
+}
+
+// Import загрузка записей из dec в хранилище с сохранением идентификаторов и времени.
+// Загрузка останавливается на первой ошибке, уже загруженные записи остаются. С skipExisting записи,
+// уже бывшие в хранилище, пропускаются, поэтому прерванную загрузку можно повторить с той же выгрузкой.
+// Пользователь пропускается, только если в хранилище он с тем же id и именем, заказ - если он того же пользователя
+func Import(ctx context.Context, s storage.Storage, dec Decoder, skipExisting bool) (Counts, error) {
+	var counts Counts
+	for {
+		record, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, fmt.Errorf("ошибка чтения выгрузки: %w", err)
+		}
+
+		switch {
+		case record.Type == TypeUser && record.User != nil:
+			u := record.User
+			err := s.ImportUser(ctx, model.User{
+				ID:           u.ID,
+				Name:         u.Name,
+				PasswordHash: u.PasswordHash,
+				Role:         u.Role,
+				Balance:      u.Balance,
+				Withdrawn:    u.Withdrawn,
+			})
+			if skipExisting && errors.Is(err, errs.ErrUserExists) {
+				if existing, getErr := s.GetUserByID(ctx, u.ID); getErr == nil && existing.Name == u.Name {
+					counts.Skipped++
+					continue
+				}
+			}
+			if err != nil {
+				return counts, fmt.Errorf("ошибка загрузки пользователя %d %s: %w", u.ID, u.Name, err)
+			}
+			counts.Users++
+		case record.Type == TypeOrder && record.Order != nil:
+			o := record.Order
+			err := s.ImportOrder(ctx, model.Order{
+				Number:     o.Number,
+				Status:     o.Status,
+				Accrual:    o.Accrual,
+				UploadedAt: o.UploadedAt,
+				UserID:     o.UserID,
+				Attempts:   o.Attempts,
+			})
+			if skipExisting && errors.Is(err, errs.ErrExistsSameUser) {
+				counts.Skipped++
+				continue
+			}
+			if err != nil {
+				return counts, fmt.Errorf("ошибка загрузки заказа %s: %w", o.Number, err)
+			}
+			counts.Orders++
+		case record.Type == TypeWithdrawal && record.Withdrawal != nil:
+			w := record.Withdrawal
+			err := s.ImportWithdrawal(ctx, model.Withdrawal{
+				UserID:      w.UserID,
+				Order:       w.Order,
+				Sum:         w.Sum,
+				ProcessedAt: w.ProcessedAt,
+			})
+			if skipExisting && errors.Is(err, errs.ErrWithdrawalExists) {
+				counts.Skipped++
+				continue
+			}
+			if err != nil {
+				return counts, fmt.Errorf("ошибка загрузки списания %s: %w", w.Order, err)
+			}
+			counts.Withdrawals++
+		default:
+			return counts, fmt.Errorf("неизвестная запись выгрузки типа %q", record.Type)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/superles/yapgofermart/internal/storage/sqlitestorage"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fillSource хранилище с пользователями, заказами с начислением и без, и списанием
+func fillSource(t *testing.T) storage.Storage {
+	ctx := context.Background()
+	s, err := memstorage.NewStorage()
+	require.NoError(t, err)
+
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	other, err := s.RegisterUser(ctx, model.User{Name: "other, \"quoted\"", PasswordHash: "hash2", Role: model.RoleAdmin})
+	require.NoError(t, err)
+
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+	require.NoError(t, s.CreateNewOrder(ctx, "2377225624", other.ID))
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100.5))
+	require.NoError(t, storage.Withdraw(ctx, s, "79927398713", 40.25, user.ID))
+	return s
+}
+
+func exportAll(t *testing.T, s storage.Storage) []Record {
+	var buf bytes.Buffer
+	enc := NewJSONLEncoder(&buf)
+	_, err := Export(context.Background(), s, enc)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+
+	var records []Record
+	dec := NewJSONLDecoder(&buf)
+	for {
+		record, err := dec.Decode()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func newSqlite(t *testing.T) storage.Storage {
+	s, err := sqlitestorage.NewStorage(filepath.Join(t.TempDir(), "gophermart.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(t *testing.T) (Encoder, func() Decoder)
+	}{
+		{"#1 jsonl", func(t *testing.T) (Encoder, func() Decoder) {
+			var buf bytes.Buffer
+			return NewJSONLEncoder(&buf), func() Decoder { return NewJSONLDecoder(&buf) }
+		}},
+		{"#2 csv", func(t *testing.T) (Encoder, func() Decoder) {
+			dir := t.TempDir()
+			enc, err := NewCSVEncoder(dir)
+			require.NoError(t, err)
+			return enc, func() Decoder {
+				dec, err := NewCSVDecoder(dir)
+				require.NoError(t, err)
+				return dec
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := fillSource(t)
+			enc, decoder := tt.encode(t)
+
+			exported, err := Export(ctx, source, enc)
+			require.NoError(t, err)
+			require.NoError(t, enc.Close())
+			assert.Equal(t, Counts{Users: 2, Orders: 2, Withdrawals: 1}, exported)
+
+			target := newSqlite(t)
+			dec := decoder()
+			imported, err := Import(ctx, target, dec, false)
+			require.NoError(t, err)
+			require.NoError(t, dec.Close())
+			assert.Equal(t, exported, imported)
+
+			assert.Equal(t, exportAll(t, source), exportAll(t, target))
+
+			user, err := target.GetUserByName(ctx, "user")
+			require.NoError(t, err)
+			assert.Equal(t, 100.5-40.25, user.Balance)
+			assert.Equal(t, 40.25, user.Withdrawn)
+		})
+	}
+}
+
+func TestImportSkipExisting(t *testing.T) {
+	ctx := context.Background()
+	source := fillSource(t)
+	records := exportAll(t, source)
+	var buf bytes.Buffer
+	enc := NewJSONLEncoder(&buf)
+	for _, record := range records {
+		require.NoError(t, enc.Encode(record))
+	}
+	require.NoError(t, enc.Close())
+	input := buf.String()
+
+	// прерванная загрузка: в хранилище уже есть часть записей
+	target := newSqlite(t)
+	lines := strings.SplitAfter(input, "\n")
+	_, err := Import(ctx, target, NewJSONLDecoder(strings.NewReader(strings.Join(lines[:3], ""))), false)
+	require.NoError(t, err)
+
+	_, err = Import(ctx, target, NewJSONLDecoder(strings.NewReader(input)), false)
+	assert.Error(t, err, "повтор без пропуска останавливается на первой загруженной записи")
+
+	imported, err := Import(ctx, target, NewJSONLDecoder(strings.NewReader(input)), true)
+	require.NoError(t, err)
+	assert.Equal(t, Counts{Orders: 1, Withdrawals: 1, Skipped: 3}, imported)
+	assert.Equal(t, records, exportAll(t, target))
+
+	imported, err = Import(ctx, target, NewJSONLDecoder(strings.NewReader(input)), true)
+	require.NoError(t, err)
+	assert.Equal(t, Counts{Skipped: 5}, imported, "повторная загрузка ничего не меняет")
+	assert.Equal(t, records, exportAll(t, target))
+
+	_, err = Import(ctx, target, NewJSONLDecoder(strings.NewReader(`{"type":"user","user":{"id":1,"name":"renamed"}}`)), true)
+	assert.Error(t, err, "пользователь с тем же id и другим именем не пропускается")
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"#1 unknown type", `{"type":"accrual"}`, "неизвестная запись"},
+		{"#2 malformed", `{"type":"user",`, "ошибка чтения выгрузки"},
+		{"#3 duplicate user", `{"type":"user","user":{"id":1,"name":"a"}}` + "\n" + `{"type":"user","user":{"id":1,"name":"b"}}`, "ошибка загрузки пользователя 1 b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := memstorage.NewStorage()
+			require.NoError(t, err)
+			_, err = Import(context.Background(), s, NewJSONLDecoder(strings.NewReader(tt.input)), false)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestCSVDecoderEmpty(t *testing.T) {
+	dir := t.TempDir()
+	enc, err := NewCSVEncoder(dir)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+
+	dec, err := NewCSVDecoder(dir)
+	require.NoError(t, err)
+	_, err = dec.Decode()
+	assert.ErrorIs(t, err, io.EOF, "пустая выгрузка")
+
+	_, err = NewCSVDecoder(filepath.Join(dir, UsersFile))
+	assert.Error(t, err, "файл вместо каталога")
+}
@@ -0,0 +1,49 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// JSONLEncoder выгрузка в JSON Lines: одна запись Record на строку
+type JSONLEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewJSONLEncoder(w io.Writer) *JSONLEncoder {
+	buf := bufio.NewWriter(w)
+	return &JSONLEncoder{w: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *JSONLEncoder) Encode(record Record) error {
+	return e.enc.Encode(record)
+}
+
+// Close запись буфера, w не закрывается
+func (e *JSONLEncoder) Close() error {
+	return e.w.Flush()
+}
+
+// JSONLDecoder чтение выгрузки в JSON Lines
+type JSONLDecoder struct {
+	dec *json.Decoder
+}
+
+func NewJSONLDecoder(r io.Reader) *JSONLDecoder {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.DisallowUnknownFields()
+	return &JSONLDecoder{dec: dec}
+}
+
+func (d *JSONLDecoder) Decode() (Record, error) {
+	var record Record
+	err := d.dec.Decode(&record)
+	return record, err
+}
+
+// Close ничего не делает, r закрывается вызывающим
+func (d *JSONLDecoder) Close() error {
+	return nil
+}
@@ -0,0 +1,13 @@
+package errors
+
+import "errors"
+
+var (
+	ErrBadRequest         = errors.New("неверный формат запроса")
+	ErrInvalidOrderNumber = errors.New("неверный формат номера заказа")
+	ErrInvalidCredentials = errors.New("неверная пара логин/пароль")
+	ErrUnauthorized       = errors.New("требуется авторизация")
+	ErrForbidden          = errors.New("доступ запрещён")
+	ErrInvalidSignature   = errors.New("неверная подпись запроса")
+	ErrMethodNotAllowed   = errors.New("метод не поддерживается")
+)
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Стабильные коды ошибок API, клиенты опираются на них, а не на текст
+const (
+	CodeBadRequest          = "bad_request"
+	CodeInvalidOrderNumber  = "invalid_order_number"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeInvalidSignature    = "invalid_signature"
+	CodeNotFound            = "not_found"
+	CodeUserExists          = "user_exists"
+	CodeOrderExists         = "order_exists"
+	CodeOrderConflict       = "order_conflict"
+	CodeInsufficientBalance = "insufficient_balance"
+	CodeRewardExists        = "reward_exists"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeInternal            = "internal_error"
+)
+
+type mapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// mappings соответствие ошибок HTTP статусам и кодам, проверяется по порядку через errors.Is
+var mappings = []mapping{
+	{ErrBadRequest, http.StatusBadRequest, CodeBadRequest},
+	{ErrInvalidOrderNumber, http.StatusUnprocessableEntity, CodeInvalidOrderNumber},
+	{ErrInvalidCredentials, http.StatusUnauthorized, CodeInvalidCredentials},
+	{ErrUnauthorized, http.StatusUnauthorized, CodeUnauthorized},
+	{ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{ErrInvalidSignature, http.StatusUnauthorized, CodeInvalidSignature},
+	{ErrMethodNotAllowed, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	{ErrNoRows, http.StatusNotFound, CodeNotFound},
+	{ErrUserExists, http.StatusConflict, CodeUserExists},
+	{ErrExistsSameUser, http.StatusConflict, CodeOrderExists},
+	{ErrExistsAnotherUser, http.StatusConflict, CodeOrderConflict},
+	{ErrWithdrawalNotEnoughBalance, http.StatusPaymentRequired, CodeInsufficientBalance},
+}
+
+// Lookup HTTP статус и код ошибки, known false для ошибок без сопоставления,
+// для них возвращается 500 internal_error
+func Lookup(err error) (status int, code string, known bool) {
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.code, true
+		}
+	}
+	return http.StatusInternalServerError, CodeInternal, false
+}
@@ -0,0 +1,31 @@
+package errors
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantKnown  bool
+	}{
+		{"#1 sentinel", ErrWithdrawalNotEnoughBalance, http.StatusPaymentRequired, CodeInsufficientBalance, true},
+		{"#2 wrapped", fmt.Errorf("заказ 123: %w", ErrExistsAnotherUser), http.StatusConflict, CodeOrderConflict, true},
+		{"#3 invalid number", ErrInvalidOrderNumber, http.StatusUnprocessableEntity, CodeInvalidOrderNumber, true},
+		{"#4 unknown", fmt.Errorf("connection refused"), http.StatusInternalServerError, CodeInternal, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code, known := Lookup(tt.err)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantKnown, known)
+		})
+	}
+}
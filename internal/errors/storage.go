@@ -6,4 +6,10 @@ var (
 	ErrNoRows            = errors.New("не найдено записей")
 	ErrExistsSameUser    = errors.New("номер заказа уже был загружен этим пользователем")
 	ErrExistsAnotherUser = errors.New("номер заказа уже был загружен другим пользователем")
+	ErrOrderFinal        = errors.New("заказ уже в конечном статусе")
+	ErrWithdrawalExists  = errors.New("списание уже загружено")
+)
+
+var (
+	ErrUserExists = errors.New("пользователь с таким именем уже существует")
 )
@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	errs "github.com/superles/yapgofermart/internal/errors"
+)
+
+// Ключи сообщений помимо кодов ошибок из internal/errors, которые сами являются ключами каталога
+const (
+	MsgWithdrawalDone       = "withdrawal_done"
+	MsgOrderAlreadyAccepted = "order_already_accepted"
+	MsgNotFoundRoute        = "route_not_found"
+)
+
+// catalog сообщения по языку и ключу
+var catalog = map[string]map[string]string{
+	Russian: {
+		errs.CodeBadRequest:          "неверный формат запроса",
+		errs.CodeInvalidOrderNumber:  "неверный формат номера заказа",
+		errs.CodeInvalidCredentials:  "неверная пара логин/пароль",
+		errs.CodeUnauthorized:        "требуется авторизация",
+		errs.CodeForbidden:           "доступ запрещён",
+		errs.CodeInvalidSignature:    "неверная подпись запроса",
+		errs.CodeNotFound:            "не найдено",
+		errs.CodeUserExists:          "пользователь с таким именем уже существует",
+		errs.CodeOrderExists:         "номер заказа уже был загружен этим пользователем",
+		errs.CodeOrderConflict:       "номер заказа уже был загружен другим пользователем",
+		errs.CodeInsufficientBalance: "на счету недостаточно средств",
+		errs.CodeRewardExists:        "ключ поиска уже зарегистрирован",
+		errs.CodeMethodNotAllowed:    "метод не поддерживается",
+		errs.CodeInternal:            "ошибка сервера",
+		MsgWithdrawalDone:            "списано успешно",
+		MsgOrderAlreadyAccepted:      "заказ уже принят в обработку",
+		MsgNotFoundRoute:             "метод не найден",
+	},
+	English: {
+		errs.CodeBadRequest:          "invalid request format",
+		errs.CodeInvalidOrderNumber:  "invalid order number",
+		errs.CodeInvalidCredentials:  "invalid login or password",
+		errs.CodeUnauthorized:        "authorization required",
+		errs.CodeForbidden:           "access denied",
+		errs.CodeInvalidSignature:    "invalid request signature",
+		errs.CodeNotFound:            "not found",
+		errs.CodeUserExists:          "a user with this login already exists",
+		errs.CodeOrderExists:         "the order number has already been uploaded by this user",
+		errs.CodeOrderConflict:       "the order number has already been uploaded by another user",
+		errs.CodeInsufficientBalance: "insufficient balance",
+		errs.CodeRewardExists:        "the search key is already registered",
+		errs.CodeMethodNotAllowed:    "method not allowed",
+		errs.CodeInternal:            "internal server error",
+		MsgWithdrawalDone:            "withdrawal completed",
+		MsgOrderAlreadyAccepted:      "the order has already been accepted for processing",
+		MsgNotFoundRoute:             "route not found",
+	},
+}
@@ -0,0 +1,77 @@
+// Package i18n каталог сообщений API на поддерживаемых языках и выбор языка по Accept-Language
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+// DefaultLanguage язык сообщений, если клиент не указал поддерживаемый
+const DefaultLanguage = Russian
+
+// Supported проверка, есть ли каталог сообщений для языка lang
+func Supported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// Message сообщение по ключу на языке lang. Если перевода нет, используется язык по умолчанию,
+// если нет и его - сам ключ
+func Message(lang string, key string) string {
+	if msg, ok := catalog[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := catalog[DefaultLanguage][key]; ok {
+		return msg
+	}
+	return key
+}
+
+type weighted struct {
+	lang string
+	q    float64
+}
+
+// Negotiate выбор языка из заголовка Accept-Language по весам q, для региональных вариантов
+// (en-US) используется основной язык. Если ни один язык не поддерживается, возвращается def
+func Negotiate(acceptLanguage string, def string) string {
+	var candidates []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if len(tag) == 0 {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		candidates = append(candidates, weighted{lang: base, q: q})
+	}
+	// при равных весах сохраняется порядок клиента
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	for _, c := range candidates {
+		if c.lang == "*" {
+			return def
+		}
+		if Supported(c.lang) {
+			return c.lang
+		}
+	}
+	return def
+}
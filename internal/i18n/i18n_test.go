@@ -0,0 +1,38 @@
+package i18n
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		def            string
+		want           string
+	}{
+		{"#1 empty", "", Russian, Russian},
+		{"#2 region", "en-GB", Russian, English},
+		{"#3 weights", "ru;q=0.5, en;q=0.8", Russian, English},
+		{"#4 unsupported first", "de-DE, en;q=0.7", Russian, English},
+		{"#5 unsupported only", "de, fr", English, English},
+		{"#6 wildcard", "*", English, English},
+		{"#7 zero weight", "en;q=0, ru", English, Russian},
+		{"#8 equal weights keep order", "ru, en", English, Russian},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.acceptLanguage, tt.def))
+		})
+	}
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for lang, messages := range catalog {
+		for key := range catalog[DefaultLanguage] {
+			assert.Contains(t, messages, key, "нет перевода %s на %s", key, lang)
+		}
+	}
+	assert.Equal(t, "unknown_key", Message(English, "unknown_key"))
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+)
+
+// AccrualLog запись журнала ответов системы расчёта
+type AccrualLog struct {
+	ID        int64     `json:"id"`
+	Order     string    `json:"order"`             // Номер заказа
+	Status    string    `json:"status"`            // Статус заказа в ответе системы расчёта
+	Accrual   *float64  `json:"accrual,omitempty"` // Начисление в ответе системы расчёта
+	HTTPCode  int       `json:"http_code"`         // HTTP статус ответа, 0 - ответ получен не по HTTP
+	Error     string    `json:"error,omitempty"`   // Ошибка запроса
+	CreatedAt time.Time `json:"created_at"`        // Время получения ответа
+}
@@ -9,6 +9,7 @@ const (
 	OrderStatusProcessing = "PROCESSING" // OrderStatusProcessing заказ загружен в систему, но не попал в обработку
 	OrderStatusInvalid    = "INVALID"    // OrderStatusInvalid заказ загружен в систему, но не попал в обработку
 	OrderStatusProcessed  = "PROCESSED"  // OrderStatusProcessed заказ загружен в систему, но не попал в обработку
+	OrderStatusStuck      = "STUCK"      // OrderStatusStuck заказ исчерпал лимит времени или попыток опроса системы расчёта
 )
 
 type Order struct {
@@ -17,4 +18,5 @@ type Order struct {
 	Accrual    *float64  `json:"accrual,omitempty"` // Рассчитанные баллы к начислению
 	UploadedAt time.Time `json:"uploaded_at"`       // Дата загрузки товара
 	UserID     int64     // UserID - id пользователя заказа
+	Attempts   int       // Attempts - количество запросов заказа в систему расчёта
 }
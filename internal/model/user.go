@@ -15,4 +15,5 @@ type User struct {
 	PasswordHash string  // Хеш пароля пользователя
 	Role         string  // Роль пользователя
 	Balance      float64 // Роль пользователя
+	Withdrawn    float64 // Сумма списанных баллов за всё время
 }
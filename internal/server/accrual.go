@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	fastRouter "github.com/fasthttp/router"
+	"github.com/superles/yapgofermart/internal/accrual"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/i18n"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signatureHeader          = "X-Signature"
+	signatureTimestampHeader = "X-Signature-Timestamp"
+	// signatureMaxSkew допустимое расхождение времени подписи push запроса с временем сервера
+	signatureMaxSkew = 5 * time.Minute
+)
+
+type accrualPushResponse struct {
+	Processed int      `json:"processed"`
+	Skipped   []string `json:"skipped,omitempty"` // Skipped заказы в конечном статусе, повторный push их не меняет
+	Failed    []string `json:"failed,omitempty"`
+}
+
+// registerAccrualPushRoutes регистрация роута push начислений, если задан ключ подписи
+func (s *Server) registerAccrualPushRoutes(router *fastRouter.Router, middleware func(fasthttp.RequestHandler) fasthttp.RequestHandler) {
+	if len(s.cfg.AccrualPushKey) == 0 {
+		return
+	}
+	router.POST("/api/internal/accrual", middleware(s.signatureMiddleware(s.accrualPushHandler)))
+}
+
+// signatureMiddleware проверка HMAC-SHA256 подписи в заголовке X-Signature: sha256=<hex> от времени
+// из X-Signature-Timestamp (unix секунды) и тела запроса. Запрос с временем дальше signatureMaxSkew отклоняется,
+// поэтому перехваченный запрос нельзя повторить позже
+func (s *Server) signatureMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		timestamp := string(ctx.Request.Header.Peek(signatureTimestampHeader))
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			writeError(ctx, fmt.Errorf("%w: нет времени подписи", errs.ErrInvalidSignature))
+			return
+		}
+		if skew := time.Since(time.Unix(unix, 0)); skew > signatureMaxSkew || skew < -signatureMaxSkew {
+			writeError(ctx, fmt.Errorf("%w: время подписи вне допустимого окна", errs.ErrInvalidSignature))
+			return
+		}
+
+		signature := string(ctx.Request.Header.Peek(signatureHeader))
+		signature, ok := strings.CutPrefix(signature, "sha256=")
+		if !ok {
+			writeError(ctx, fmt.Errorf("%w: нет подписи", errs.ErrInvalidSignature))
+			return
+		}
+		got, err := hex.DecodeString(signature)
+		if err != nil {
+			writeError(ctx, errs.ErrInvalidSignature)
+			return
+		}
+		if !hmac.Equal(got, SignPush(timestamp, ctx.Request.Body(), []byte(s.cfg.AccrualPushKey))) {
+			writeError(ctx, errs.ErrInvalidSignature)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// SignPush HMAC-SHA256 подпись push запроса: время из X-Signature-Timestamp, точка и тело
+func SignPush(timestamp string, body []byte, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// accrualPushHandler приём начислений по пачке заказов от системы расчёта
+func (s *Server) accrualPushHandler(ctx *fasthttp.RequestCtx) {
+	if !bytes.Contains(ctx.Request.Header.ContentType(), []byte("application/json")) {
+		writeError(ctx, errs.ErrBadRequest)
+		return
+	}
+
+	var accruals []accrual.Accrual
+	if err := json.Unmarshal(ctx.Request.Body(), &accruals); err != nil {
+		logger.FromContext(ctx).Errorf("ошибка декода запроса: %s", err.Error())
+		writeError(ctx, errs.ErrBadRequest)
+		return
+	}
+
+	var response accrualPushResponse
+	for _, a := range accruals {
+		s.service.Audit(ctx, a.Number, a, nil)
+		err := s.service.ApplyAccrual(ctx, a)
+		if errors.Is(err, errs.ErrOrderFinal) {
+			logger.FromContext(ctx).Infof("push начисления пропущен: %s", err.Error())
+			response.Skipped = append(response.Skipped, a.Number)
+			continue
+		}
+		if err != nil {
+			logger.FromContext(ctx).Errorf("push начисления: %s", err.Error())
+			response.Failed = append(response.Failed, a.Number)
+			continue
+		}
+		response.Processed++
+	}
+
+	if data, err := json.Marshal(response); err != nil {
+		writeError(ctx, fmt.Errorf("ошибка запроса сериализации: %w", err))
+	} else {
+		ctx.Response.Header.Set("Content-Type", "application/json")
+		ctx.Response.SetStatusCode(fasthttp.StatusOK)
+		ctx.Response.SetBody(data)
+	}
+}
+
+// registerLocalAccrualRoutes регистрация роутов встроенной системы расчёта, если она используется вместо внешней.
+// Регистрация заказа сразу начисляет баллы, поэтому роуты доступны только администратору
+func (s *Server) registerLocalAccrualRoutes(router *fastRouter.Router, middleware func(fasthttp.RequestHandler) fasthttp.RequestHandler) {
+	client, ok := s.service.Client.(*accrual.LocalClient)
+	if !ok {
+		return
+	}
+	router.POST("/api/accrual/orders", middleware(func(ctx *fasthttp.RequestCtx) {
+		registerAccrualOrderHandler(ctx, client)
+	}))
+	router.POST("/api/accrual/goods", middleware(func(ctx *fasthttp.RequestCtx) {
+		registerAccrualRewardHandler(ctx, client)
+	}))
+}
+
+func registerAccrualOrderHandler(ctx *fasthttp.RequestCtx, client *accrual.LocalClient) {
+	if !bytes.Contains(ctx.Request.Header.ContentType(), []byte("application/json")) {
+		writeError(ctx, errs.ErrBadRequest)
+		return
+	}
+
+	var registration accrual.OrderRegistration
+	if err := json.Unmarshal(ctx.Request.Body(), &registration); err != nil {
+		logger.FromContext(ctx).Errorf("ошибка декода запроса: %s", err.Error())
+		writeError(ctx, errs.ErrBadRequest)
+		return
+	}
+
+	err := client.RegisterOrder(registration)
+	switch {
+	case err == nil:
+		ctx.SetStatusCode(fasthttp.StatusAccepted)
+	case errors.Is(err, accrual.ErrOrderAlreadyRegistered):
+		writeProblem(ctx, fasthttp.StatusConflict, errs.CodeOrderExists, i18n.MsgOrderAlreadyAccepted)
+	case errors.Is(err, accrual.ErrInvalidOrder):
+		writeError(ctx, errs.ErrBadRequest)
+	default:
+		writeError(ctx, fmt.Errorf("ошибка регистрации заказа в системе расчёта: %w", err))
+	}
+}
+
+func registerAccrualRewardHandler(ctx *fasthttp.RequestCtx, client *accrual.LocalClient) {
+	if !bytes.Contains(ctx.Request.Header.ContentType(), []byte("application/json")) {
+		writeError(ctx, errs.ErrBadRequest)
+		return
+	}
+
+	var reward accrual.Reward
+	if err := json.Unmarshal(ctx.Request.Body(), &reward); err != nil {
+		logger.FromContext(ctx).Errorf("ошибка декода запроса: %s", err.Error())
+		writeError(ctx, errs.ErrBadRequest)
+		return
+	}
+
+	err := client.AddReward(reward)
+	switch {
+	case err == nil:
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	case errors.Is(err, accrual.ErrRewardAlreadyExists):
+		writeProblem(ctx, fasthttp.StatusConflict, errs.CodeRewardExists, errs.CodeRewardExists)
+	case errors.Is(err, accrual.ErrInvalidReward):
+		writeError(ctx, errs.ErrBadRequest)
+	default:
+		writeError(ctx, fmt.Errorf("ошибка добавления правила вознаграждения: %w", err))
+	}
+}
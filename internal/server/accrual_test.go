@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestServer_accrualPushHandler(t *testing.T) {
+	type args struct {
+		body       string
+		timestamp  string
+		signature  string
+		statusCode int
+		response   *accrualPushResponse
+	}
+
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+
+	users := generateTestUsers(t, memStorage)
+	require.NoError(t, memStorage.CreateNewOrder(context.Background(), "123456789049", users[0].ID))
+	require.NoError(t, memStorage.CreateNewOrder(context.Background(), "2377225624", users[0].ID))
+	require.NoError(t, memStorage.CreateNewOrder(context.Background(), "79927398713", users[0].ID))
+
+	key := "push"
+	s := &Server{
+		cfg:     &config.Config{AccrualPushKey: key},
+		storage: memStorage,
+		service: accrual.Service{Storage: memStorage},
+	}
+	handler := s.signatureMiddleware(s.accrualPushHandler)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	sign := func(timestamp string, body string) string {
+		return "sha256=" + hex.EncodeToString(SignPush(timestamp, []byte(body), []byte(key)))
+	}
+	pushBody := `[{"order":"123456789049","status":"PROCESSED","accrual":500},{"order":"2377225624","status":"INVALID"}]`
+	replayBody := `[{"order":"123456789049","status":"PROCESSED","accrual":500},{"order":"79927398713","status":"PROCESSING"}]`
+
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "#1 no signature",
+			args: args{pushBody, now, "", fasthttp.StatusUnauthorized, nil},
+		},
+		{
+			name: "#2 wrong signature",
+			args: args{pushBody, now, sign(now, `[]`), fasthttp.StatusUnauthorized, nil},
+		},
+		{
+			name: "#3 no timestamp",
+			args: args{pushBody, "", sign("", pushBody), fasthttp.StatusUnauthorized, nil},
+		},
+		{
+			name: "#4 expired timestamp",
+			args: args{pushBody, expired, sign(expired, pushBody), fasthttp.StatusUnauthorized, nil},
+		},
+		{
+			name: "#5 timestamp not covered by signature",
+			args: args{pushBody, now, sign(expired, pushBody), fasthttp.StatusUnauthorized, nil},
+		},
+		{
+			name: "#6 bad request",
+			args: args{`{`, now, sign(now, `{`), fasthttp.StatusBadRequest, nil},
+		},
+		{
+			name: "#7 positive push",
+			args: args{pushBody, now, sign(now, pushBody), fasthttp.StatusOK, &accrualPushResponse{Processed: 2}},
+		},
+		{
+			name: "#8 replay of processed order is skipped",
+			args: args{replayBody, now, sign(now, replayBody), fasthttp.StatusOK, &accrualPushResponse{Processed: 1, Skipped: []string{"123456789049"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqCtx := createRequestWithBodyAndContentType(tt.args.body, "application/json")
+			reqCtx.Request.Header.Set(signatureHeader, tt.args.signature)
+			reqCtx.Request.Header.Set(signatureTimestampHeader, tt.args.timestamp)
+			handler(reqCtx)
+			assert.Equal(t, tt.args.statusCode, reqCtx.Response.StatusCode())
+			if tt.args.response != nil {
+				var response accrualPushResponse
+				require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &response))
+				assert.Equal(t, *tt.args.response, response)
+			}
+		})
+	}
+
+	user, err := memStorage.GetUserByID(context.Background(), users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(500), user.Balance)
+
+	order, err := memStorage.GetOrder(context.Background(), "2377225624")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusInvalid, order.Status)
+
+	order, err = memStorage.GetOrder(context.Background(), "79927398713")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusProcessing, order.Status)
+}
+
+func TestServer_localAccrualRoutes(t *testing.T) {
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	users := generateTestUsers(t, memStorage)
+	client, err := accrual.NewLocalClient(nil)
+	require.NoError(t, err)
+	s := New(&config.Config{SecretKeyBytes: []byte("test")}, memStorage, accrual.Service{Client: client})
+	router, err := s.newRouter()
+	require.NoError(t, err)
+
+	admin := users[1]
+	admin.Role = model.RoleAdmin
+	userToken, err := s.GetAuthToken(users[0])
+	require.NoError(t, err)
+	adminToken, err := s.GetAuthToken(admin)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		statusCode int
+	}{
+		{"#1 anonymous", "", `{"order":"12345678903","goods":[]}`, fasthttp.StatusUnauthorized},
+		{"#2 not admin", userToken, `{"order":"12345678903","goods":[]}`, fasthttp.StatusForbidden},
+		{"#3 invalid order number", adminToken, `{"order":"12345678904","goods":[]}`, fasthttp.StatusBadRequest},
+		{"#4 admin", adminToken, `{"order":"12345678903","goods":[{"description":"Утюг","price":100}]}`, fasthttp.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqCtx := createRequestWithBodyAndContentType(tt.body, "application/json")
+			reqCtx.Request.Header.SetMethod(fasthttp.MethodPost)
+			reqCtx.Request.SetRequestURI("/api/accrual/orders")
+			if len(tt.token) > 0 {
+				reqCtx.Request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			router.Handler(reqCtx)
+			assert.Equal(t, tt.statusCode, reqCtx.Response.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"time"
+)
+
+type stuckOrderJSON struct {
+	Number     string `json:"number"`      // Номер заказа
+	UserID     int64  `json:"user_id"`     // Пользователь заказа
+	Attempts   int    `json:"attempts"`    // Количество запросов в систему расчёта
+	UploadedAt string `json:"uploaded_at"` // Дата загрузки товара
+}
+
+// adminMiddleware проверка роли администратора, используется после authMiddleware
+func (s *Server) adminMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		role, _ := ctx.UserValue("userRole").(string)
+		if role != model.RoleAdmin {
+			writeError(ctx, errs.ErrForbidden)
+			return
+		}
+		next(ctx)
+	}
+}
+
+func (s *Server) getStuckOrdersHandler(ctx *fasthttp.RequestCtx) {
+	orders, err := s.storage.GetAllStuckOrders(ctx)
+	if err != nil {
+		writeError(ctx, fmt.Errorf("ошибка запроса зависших заказов: %w", err))
+		return
+	}
+
+	if len(orders) == 0 {
+		ctx.Response.SetStatusCode(fasthttp.StatusNoContent)
+		return
+	}
+
+	jsonOrders := make([]stuckOrderJSON, len(orders))
+	for i, order := range orders {
+		jsonOrders[i] = stuckOrderJSON{
+			Number:     order.Number,
+			UserID:     order.UserID,
+			Attempts:   order.Attempts,
+			UploadedAt: order.UploadedAt.Format(time.RFC3339),
+		}
+	}
+	writeJSON(ctx, jsonOrders)
+}
+
+func (s *Server) requeueStuckOrderHandler(ctx *fasthttp.RequestCtx) {
+	number, _ := ctx.UserValue("number").(string)
+
+	err := s.storage.RequeueStuckOrder(ctx, number)
+	if err == nil {
+		logger.FromContext(ctx).Infof("заказ %s возвращён в очередь опроса", number)
+		ctx.SetStatusCode(fasthttp.StatusAccepted)
+		return
+	}
+
+	if errors.Is(err, errs.ErrNoRows) {
+		writeError(ctx, fmt.Errorf("зависший заказ %s не найден: %w", number, err))
+		return
+	}
+
+	writeError(ctx, fmt.Errorf("ошибка возврата заказа %s в очередь: %w", number, err))
+}
+
+func (s *Server) getOrderAccrualLogHandler(ctx *fasthttp.RequestCtx) {
+	number, _ := ctx.UserValue("number").(string)
+
+	entries, err := s.storage.GetAccrualLogsByOrder(ctx, number)
+	if err != nil {
+		writeError(ctx, fmt.Errorf("ошибка запроса журнала начислений заказа %s: %w", number, err))
+		return
+	}
+
+	if len(entries) == 0 {
+		ctx.Response.SetStatusCode(fasthttp.StatusNoContent)
+		return
+	}
+
+	writeJSON(ctx, entries)
+}
+
+func (s *Server) getReconciliationHandler(ctx *fasthttp.RequestCtx) {
+	report := s.service.LastReconciliation()
+	if report == nil {
+		ctx.Response.SetStatusCode(fasthttp.StatusNoContent)
+		return
+	}
+
+	writeJSON(ctx, report)
+}
+
+// writeJSON сериализация ответа в json со статусом 200
+func writeJSON(ctx *fasthttp.RequestCtx, v any) {
+	if data, err := json.Marshal(v); err != nil {
+		writeError(ctx, fmt.Errorf("ошибка запроса сериализации: %w", err))
+	} else {
+		ctx.Response.Header.Set("Content-Type", "application/json")
+		ctx.Response.SetStatusCode(fasthttp.StatusOK)
+		ctx.Response.SetBody(data)
+	}
+}
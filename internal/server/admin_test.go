@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+func TestServer_stuckOrdersHandlers(t *testing.T) {
+	ctx := context.Background()
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+
+	users := generateTestUsers(t, memStorage)
+	require.NoError(t, memStorage.CreateNewOrder(ctx, "123456789049", users[0].ID))
+	require.NoError(t, memStorage.IncrementOrderAttempts(ctx, "123456789049"))
+
+	s := &Server{storage: memStorage}
+	handler := s.adminMiddleware(s.getStuckOrdersHandler)
+
+	reqCtx := createRequestWithBody("")
+	authCtxWithUser(reqCtx, users[0])
+	handler(reqCtx)
+	assert.Equal(t, fasthttp.StatusForbidden, reqCtx.Response.StatusCode())
+
+	admin := users[0]
+	admin.Role = model.RoleAdmin
+
+	reqCtx = createRequestWithBody("")
+	authCtxWithUser(reqCtx, admin)
+	handler(reqCtx)
+	assert.Equal(t, fasthttp.StatusNoContent, reqCtx.Response.StatusCode())
+
+	count, err := memStorage.MarkStuckOrders(ctx, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	reqCtx = createRequestWithBody("")
+	authCtxWithUser(reqCtx, admin)
+	handler(reqCtx)
+	assert.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode())
+	var stuck []stuckOrderJSON
+	require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &stuck))
+	require.Len(t, stuck, 1)
+	assert.Equal(t, "123456789049", stuck[0].Number)
+	assert.Equal(t, 1, stuck[0].Attempts)
+
+	for _, want := range []int{fasthttp.StatusAccepted, fasthttp.StatusNotFound} {
+		reqCtx = createRequestWithBody("")
+		authCtxWithUser(reqCtx, admin)
+		reqCtx.SetUserValue("number", "123456789049")
+		s.adminMiddleware(s.requeueStuckOrderHandler)(reqCtx)
+		assert.Equal(t, want, reqCtx.Response.StatusCode())
+	}
+
+	order, err := memStorage.GetOrder(ctx, "123456789049")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusNew, order.Status)
+	assert.Equal(t, 0, order.Attempts)
+}
@@ -1,11 +1,11 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	errs "github.com/superles/yapgofermart/internal/errors"
-	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/service"
 	"github.com/superles/yapgofermart/internal/utils/logger"
 	"strings"
 	"time"
@@ -14,10 +14,7 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
-const (
-	jswTokenDuration = 30 * time.Minute
-	defaultRole      = "user"
-)
+const jswTokenDuration = 30 * time.Minute
 
 // Credentials представляет структуру для аутентификации пользователя
 type Credentials struct {
@@ -41,48 +38,19 @@ func (s *Server) registerUserHandler(ctx *fasthttp.RequestCtx) {
 	err := json.Unmarshal(body, &authUser)
 
 	if err != nil {
-		ctx.Error("ошибка формата отправки", fasthttp.StatusBadRequest)
-		return
-	}
-
-	user, err := s.storage.GetUserByName(ctx, authUser.Username)
-
-	if len(user.Name) > 0 {
-		ctx.Error("пользователь уже существует", fasthttp.StatusConflict)
-		return
-	}
-
-	if err != nil && !errors.Is(err, errs.ErrNoRows) {
-		logger.Log.Errorf("ошибка запроса пользователя %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusConflict)
-		return
-	}
-
-	if len(authUser.Username) == 0 || len(authUser.Password) == 0 {
-		ctx.Error("ошибка формата отправки", fasthttp.StatusBadRequest)
+		writeError(ctx, errs.ErrBadRequest)
 		return
 	}
 
-	password, err := HashPasswordWithRandomSalt(authUser.Password)
+	user, err := s.users.Register(ctx, authUser.Username, authUser.Password)
 	if err != nil {
-		logger.Log.Errorf("ошибка хеша пароля пользователя %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+		writeError(ctx, err)
 		return
 	}
-	user = model.User{Name: authUser.Username, PasswordHash: password, Role: defaultRole}
-	var regUser model.User
-	regUser, err = s.storage.RegisterUser(ctx, user)
 
+	authToken, err := s.GetAuthToken(user)
 	if err != nil {
-		logger.Log.Errorf("ошибка регистрации пользователя %s", err.Error())
-		ctx.Error("ошибка регистрации пользователя", fasthttp.StatusInternalServerError)
-		return
-	}
-
-	authToken, err := s.GetAuthToken(regUser)
-	if err != nil {
-		logger.Log.Errorf("ошибка генерации токена: %s", err.Error())
-		ctx.Error("Failed to generate token", fasthttp.StatusInternalServerError)
+		writeError(ctx, fmt.Errorf("ошибка генерации токена: %w", err))
 		return
 	}
 
@@ -98,43 +66,20 @@ func (s *Server) loginUserHandler(ctx *fasthttp.RequestCtx) {
 	err := json.Unmarshal(body, &authUser)
 
 	if err != nil {
-		logger.Log.Errorf("ошибка формата логина: %s", err.Error())
-		ctx.Error("ошибка формата отправки", fasthttp.StatusBadRequest)
+		logger.FromContext(ctx).Errorf("ошибка формата логина: %s", err.Error())
+		writeError(ctx, errs.ErrBadRequest)
 		return
 	}
 
-	if len(authUser.Username) == 0 || len(authUser.Password) == 0 {
-		ctx.Error("ошибка формата отправки", fasthttp.StatusBadRequest)
-		return
-	}
-
-	var user model.User
-	user, err = s.storage.GetUserByName(ctx, authUser.Username)
+	user, err := s.users.Login(ctx, authUser.Username, authUser.Password)
 	if err != nil {
-		if errors.Is(err, errs.ErrNoRows) {
-			logger.Log.Errorf("пользователь не найден %s", err.Error())
-			ctx.Error("неверная пара логин/пароль", fasthttp.StatusUnauthorized)
-		} else {
-			logger.Log.Errorf("ошибка запроса пользователя %s", err.Error())
-			ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		}
-		return
-	}
-
-	if isValid, err := ValidatePassword(user.PasswordHash, authUser.Password); err != nil {
-		logger.Log.Errorf("ошибка валидации пароля %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	} else if !isValid {
-		logger.Log.Errorf("неверный пароль")
-		ctx.Error("неверная пара логин/пароль", fasthttp.StatusUnauthorized)
+		writeError(ctx, err)
 		return
 	}
 
 	authToken, err := s.GetAuthToken(user)
 	if err != nil {
-		logger.Log.Errorf("ошибка генерации токена: %s", err.Error())
-		ctx.Error("Failed to generate token", fasthttp.StatusInternalServerError)
+		writeError(ctx, fmt.Errorf("ошибка генерации токена: %w", err))
 		return
 	}
 
@@ -145,31 +90,9 @@ func (s *Server) loginUserHandler(ctx *fasthttp.RequestCtx) {
 // AuthMiddleware представляет промежуточное ПО для проверки JWT токена
 func (s *Server) authMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
-		authHeader := string(ctx.Request.Header.Peek("Authorization"))
-		if authHeader == "" {
-			ctx.Error("Authorization token is required", fasthttp.StatusUnauthorized)
-			return
-		}
-		if !strings.Contains(authHeader, "Bearer ") || len(authHeader) <= len("Bearer ") {
-			ctx.Error("Authorization token is invalid", fasthttp.StatusUnauthorized)
-			return
-		}
-		tokenString := authHeader[len("Bearer "):]
-		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return s.cfg.SecretKeyBytes, nil
-		})
-
+		claims, err := s.parseAuthHeader(string(ctx.Request.Header.Peek("Authorization")))
 		if err != nil {
-			ctx.Error("Invalid token", fasthttp.StatusUnauthorized)
-			return
-		}
-
-		claims, ok := token.Claims.(*JWTClaims)
-		if !ok || !token.Valid {
-			ctx.Error("Invalid token claims", fasthttp.StatusUnauthorized)
+			writeError(ctx, err)
 			return
 		}
 
@@ -177,7 +100,46 @@ func (s *Server) authMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHa
 		ctx.SetUserValue("userID", claims.UserID)
 		ctx.SetUserValue("userName", claims.Username)
 		ctx.SetUserValue("userRole", claims.Role)
+		logger.Attach(ctx, logger.FromContext(ctx).With(logger.UserIDKey, claims.UserID))
 
 		next(ctx)
 	}
 }
+
+// serviceContext контекст операции сервиса от имени пользователя, установленного authMiddleware
+func serviceContext(ctx *fasthttp.RequestCtx) context.Context {
+	userID, ok := ctx.UserValue("userID").(int64)
+	if !ok {
+		return ctx
+	}
+	name, _ := ctx.UserValue("userName").(string)
+	role, _ := ctx.UserValue("userRole").(string)
+	return service.WithIdentity(ctx, service.Identity{UserID: userID, Name: name, Role: role})
+}
+
+// parseAuthHeader проверка значения "Bearer <token>" из заголовка HTTP или метаданных gRPC
+func (s *Server) parseAuthHeader(authHeader string) (*JWTClaims, error) {
+	if authHeader == "" {
+		return nil, fmt.Errorf("%w: нет токена", errs.ErrUnauthorized)
+	}
+	if !strings.Contains(authHeader, "Bearer ") || len(authHeader) <= len("Bearer ") {
+		return nil, fmt.Errorf("%w: неверный формат токена", errs.ErrUnauthorized)
+	}
+	tokenString := authHeader[len("Bearer "):]
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return s.cfg.SecretKeyBytes, nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: недействительный токен", errs.ErrUnauthorized)
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("%w: недействительные данные токена", errs.ErrUnauthorized)
+	}
+	return claims, nil
+}
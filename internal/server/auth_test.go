@@ -2,6 +2,7 @@ package server
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/superles/yapgofermart/internal/accrual"
 	"github.com/superles/yapgofermart/internal/config"
 	"github.com/superles/yapgofermart/internal/storage"
 	"github.com/superles/yapgofermart/internal/storage/memstorage"
@@ -31,10 +32,7 @@ func TestServer_registerUserHandler(t *testing.T) {
 		t.Fatalf("ошибка инициализации конфига")
 	}
 
-	s := &Server{
-		cfg:     cfg,
-		storage: memStorage,
-	}
+	s := New(cfg, memStorage, accrual.Service{})
 
 	tests := []struct {
 		name   string
@@ -107,10 +105,7 @@ func TestServer_loginUserHandler(t *testing.T) {
 		t.Fatalf("ошибка инициализации конфига: %s", err.Error())
 	}
 
-	s := &Server{
-		cfg:     cfg,
-		storage: memStorage,
-	}
+	s := New(cfg, memStorage, accrual.Service{})
 
 	tests := []struct {
 		name   string
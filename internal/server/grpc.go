@@ -0,0 +1,320 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/i18n"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/service"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/pkg/gophermartpb"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	"time"
+)
+
+const (
+	grpcErrorDomain  = "gophermart"   // grpcErrorDomain домен errdetails.ErrorInfo, Reason - код ошибки из internal/errors
+	grpcRequestIDKey = "x-request-id" // grpcRequestIDKey метаданные идентификатора запроса, как X-Request-ID в HTTP
+)
+
+type grpcContextKey int
+
+// grpcLangKey язык сообщений об ошибках вызова
+const grpcLangKey grpcContextKey = iota
+
+// grpcPublicMethods методы gRPC, доступные без токена
+var grpcPublicMethods = map[string]bool{
+	gophermartpb.Gophermart_Register_FullMethodName: true,
+	gophermartpb.Gophermart_Login_FullMethodName:    true,
+}
+
+var grpcOrderStatuses = map[string]gophermartpb.OrderStatus{
+	model.OrderStatusNew:        gophermartpb.OrderStatus_ORDER_STATUS_NEW,
+	model.OrderStatusProcessing: gophermartpb.OrderStatus_ORDER_STATUS_PROCESSING,
+	model.OrderStatusInvalid:    gophermartpb.OrderStatus_ORDER_STATUS_INVALID,
+	model.OrderStatusProcessed:  gophermartpb.OrderStatus_ORDER_STATUS_PROCESSED,
+}
+
+// grpcServer gRPC API поверх тех же сервисов и авторизации, что и HTTP обработчики
+type grpcServer struct {
+	gophermartpb.UnimplementedGophermartServer
+	s    *Server
+	done <-chan struct{} // done закрывается при остановке приложения и завершает потоки WatchOrders
+}
+
+func (s *Server) newGRPCServer(done <-chan struct{}) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(s.grpcStreamInterceptor),
+	)
+	gophermartpb.RegisterGophermartServer(srv, &grpcServer{s: s, done: done})
+	return srv
+}
+
+// ServeGRPC запуск gRPC сервера на готовом listener до отмены appContext
+func (s *Server) ServeGRPC(appContext context.Context, ln net.Listener) error {
+	srv := s.newGRPCServer(appContext.Done())
+
+	logger.Log.Info(fmt.Sprintf("gRPC server started at %s", ln.Addr().String()))
+
+	go func() {
+		<-appContext.Done()
+		srv.GracefulStop()
+		logger.Log.Debug("gRPC server graceful shutdown")
+	}()
+
+	return srv.Serve(ln)
+}
+
+// grpcContext язык сообщений по метаданным accept-language и, кроме публичных методов, пользователь из authorization
+func (s *Server) grpcContext(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = context.WithValue(ctx, grpcLangKey, i18n.Negotiate(firstMetadata(md, "accept-language"), s.cfg.DefaultLanguage))
+	if grpcPublicMethods[method] {
+		return ctx, nil
+	}
+	claims, err := s.parseAuthHeader(firstMetadata(md, "authorization"))
+	if err != nil {
+		return ctx, err
+	}
+	ctx = logger.With(ctx, logger.UserIDKey, claims.UserID)
+	return service.WithIdentity(ctx, service.Identity{UserID: claims.UserID, Name: claims.Username, Role: claims.Role}), nil
+}
+
+// grpcRequestContext идентификатор запроса из метаданных x-request-id или новый и логгер запроса в контексте
+func grpcRequestContext(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := newRequestID(firstMetadata(md, grpcRequestIDKey))
+	return logger.With(ctx, logger.RequestIDKey, id), id
+}
+
+// grpcAccessLog строка журнала доступа gRPC вызова
+func grpcAccessLog(ctx context.Context, method string, start time.Time, err error) {
+	logger.FromContext(ctx).Infow("access",
+		"method", method,
+		"code", status.Code(err).String(),
+		"latency", time.Since(start),
+	)
+}
+
+func (s *Server) grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, id := grpcRequestContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIDKey, id))
+
+	ctx, err := s.grpcContext(ctx, info.FullMethod)
+	var res interface{}
+	if err == nil {
+		res, err = handler(ctx, req)
+	}
+	if err != nil {
+		err = grpcError(ctx, info.FullMethod, err)
+	}
+	grpcAccessLog(ctx, info.FullMethod, start, err)
+	return res, err
+}
+
+func (s *Server) grpcStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, id := grpcRequestContext(stream.Context())
+	_ = stream.SetHeader(metadata.Pairs(grpcRequestIDKey, id))
+
+	ctx, err := s.grpcContext(ctx, info.FullMethod)
+	if err == nil {
+		err = handler(srv, &grpcServerStream{ServerStream: stream, ctx: ctx})
+	}
+	if err != nil {
+		err = grpcError(ctx, info.FullMethod, err)
+	}
+	grpcAccessLog(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// grpcServerStream поток с контекстом, дополненным grpcContext
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcError статус gRPC по сопоставлению из internal/errors, код ошибки передаётся в errdetails.ErrorInfo.
+// Ошибки без сопоставления логируются и возвращаются как Internal без подробностей
+func grpcError(ctx context.Context, method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	httpStatus, code, known := errs.Lookup(err)
+	if !known {
+		logger.FromContext(ctx).Errorf("ошибка сервера %s: %s", method, err.Error())
+	}
+	lang, _ := ctx.Value(grpcLangKey).(string)
+	st := status.New(grpcCode(httpStatus), i18n.Message(lang, code))
+	if detailed, detailsErr := st.WithDetails(&errdetails.ErrorInfo{Reason: code, Domain: grpcErrorDomain}); detailsErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcCode код gRPC, соответствующий статусу HTTP API
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case fasthttp.StatusBadRequest, fasthttp.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case fasthttp.StatusUnauthorized:
+		return codes.Unauthenticated
+	case fasthttp.StatusPaymentRequired:
+		return codes.FailedPrecondition
+	case fasthttp.StatusForbidden:
+		return codes.PermissionDenied
+	case fasthttp.StatusNotFound:
+		return codes.NotFound
+	case fasthttp.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case fasthttp.StatusConflict:
+		return codes.AlreadyExists
+	default:
+		return codes.Internal
+	}
+}
+
+func grpcOrder(order model.Order) *gophermartpb.Order {
+	return &gophermartpb.Order{
+		Number:     order.Number,
+		Status:     grpcOrderStatuses[order.Status],
+		Accrual:    order.Accrual,
+		UploadedAt: timestamppb.New(order.UploadedAt),
+	}
+}
+
+func (g *grpcServer) Register(ctx context.Context, req *gophermartpb.Credentials) (*gophermartpb.AuthResponse, error) {
+	user, err := g.s.users.Register(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	return g.authResponse(user)
+}
+
+func (g *grpcServer) Login(ctx context.Context, req *gophermartpb.Credentials) (*gophermartpb.AuthResponse, error) {
+	user, err := g.s.users.Login(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	return g.authResponse(user)
+}
+
+func (g *grpcServer) authResponse(user model.User) (*gophermartpb.AuthResponse, error) {
+	token, err := g.s.GetAuthToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return &gophermartpb.AuthResponse{Token: token}, nil
+}
+
+func (g *grpcServer) UploadOrder(ctx context.Context, req *gophermartpb.UploadOrderRequest) (*gophermartpb.UploadOrderResponse, error) {
+	created, err := g.s.orders.Upload(ctx, req.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	return &gophermartpb.UploadOrderResponse{Created: created}, nil
+}
+
+func (g *grpcServer) ListOrders(ctx context.Context, _ *gophermartpb.ListOrdersRequest) (*gophermartpb.ListOrdersResponse, error) {
+	orders, err := g.s.orders.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &gophermartpb.ListOrdersResponse{Orders: make([]*gophermartpb.Order, len(orders))}
+	for i, order := range orders {
+		res.Orders[i] = grpcOrder(order)
+	}
+	return res, nil
+}
+
+func (g *grpcServer) GetBalance(ctx context.Context, _ *gophermartpb.GetBalanceRequest) (*gophermartpb.Balance, error) {
+	balance, err := g.s.balance.Balance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gophermartpb.Balance{Current: balance.Current, Withdrawn: balance.Withdrawn}, nil
+}
+
+func (g *grpcServer) Withdraw(ctx context.Context, req *gophermartpb.WithdrawRequest) (*gophermartpb.WithdrawResponse, error) {
+	if err := g.s.balance.Withdraw(ctx, req.GetOrder(), req.GetSum()); err != nil {
+		return nil, err
+	}
+	return &gophermartpb.WithdrawResponse{}, nil
+}
+
+func (g *grpcServer) ListWithdrawals(ctx context.Context, _ *gophermartpb.ListWithdrawalsRequest) (*gophermartpb.ListWithdrawalsResponse, error) {
+	withdrawals, err := g.s.balance.Withdrawals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &gophermartpb.ListWithdrawalsResponse{Withdrawals: make([]*gophermartpb.Withdrawal, len(withdrawals))}
+	for i, w := range withdrawals {
+		res.Withdrawals[i] = &gophermartpb.Withdrawal{
+			Order:       w.Order,
+			Sum:         w.Sum,
+			ProcessedAt: timestamppb.New(w.ProcessedAt),
+		}
+	}
+	return res, nil
+}
+
+// WatchOrders опрос заказов пользователя с интервалом GRPCWatchInterval, отправляются только изменившиеся заказы
+func (g *grpcServer) WatchOrders(_ *gophermartpb.WatchOrdersRequest, stream gophermartpb.Gophermart_WatchOrdersServer) error {
+	ctx := stream.Context()
+
+	interval := g.s.cfg.GRPCWatchInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sent := make(map[string]*gophermartpb.Order)
+	for {
+		orders, err := g.s.orders.List(ctx)
+		if err != nil {
+			return err
+		}
+		// заказы отдаются от новых к старым, в поток - в порядке загрузки
+		for i := len(orders) - 1; i >= 0; i-- {
+			order := grpcOrder(orders[i])
+			if prev, ok := sent[order.Number]; ok && proto.Equal(prev, order) {
+				continue
+			}
+			if err = stream.Send(order); err != nil {
+				return err
+			}
+			sent[order.Number] = order
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-g.done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/superles/yapgofermart/pkg/gophermartpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestGRPCClient(t *testing.T) (gophermartpb.GophermartClient, *Server) {
+	store, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	s := New(&config.Config{SecretKeyBytes: []byte("test"), GRPCWatchInterval: 10 * time.Millisecond}, store, accrual.Service{})
+
+	ln := bufconn.Listen(1024 * 1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.ServeGRPC(ctx, ln)
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		<-done
+	})
+	return gophermartpb.NewGophermartClient(conn), s
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// assertGRPCError проверка кода gRPC и кода ошибки API в ErrorInfo
+func assertGRPCError(t *testing.T, err error, code codes.Code, reason string) {
+	st, ok := status.FromError(err)
+	require.True(t, ok, "ошибка не является статусом gRPC: %v", err)
+	assert.Equal(t, code, st.Code())
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, reason, info.Reason)
+}
+
+func TestGRPCServer(t *testing.T) {
+	ctx := context.Background()
+	client, s := newTestGRPCClient(t)
+
+	var header metadata.MD
+	requestCtx := metadata.AppendToOutgoingContext(ctx, grpcRequestIDKey, "grpc-req-1")
+	auth, err := client.Register(requestCtx, &gophermartpb.Credentials{Login: "user", Password: "pass"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"grpc-req-1"}, header.Get(grpcRequestIDKey))
+	_, err = client.Register(ctx, &gophermartpb.Credentials{Login: "user", Password: "other"})
+	assertGRPCError(t, err, codes.AlreadyExists, errs.CodeUserExists)
+	_, err = client.Login(ctx, &gophermartpb.Credentials{Login: "user", Password: "other"})
+	assertGRPCError(t, err, codes.Unauthenticated, errs.CodeInvalidCredentials)
+	auth, err = client.Login(ctx, &gophermartpb.Credentials{Login: "user", Password: "pass"})
+	require.NoError(t, err)
+	authCtx := withToken(ctx, auth.Token)
+
+	_, err = client.GetBalance(ctx, &gophermartpb.GetBalanceRequest{})
+	assertGRPCError(t, err, codes.Unauthenticated, errs.CodeUnauthorized)
+
+	uploaded, err := client.UploadOrder(authCtx, &gophermartpb.UploadOrderRequest{Number: "123456789049"})
+	require.NoError(t, err)
+	assert.True(t, uploaded.Created)
+	uploaded, err = client.UploadOrder(authCtx, &gophermartpb.UploadOrderRequest{Number: "123456789049"})
+	require.NoError(t, err)
+	assert.False(t, uploaded.Created)
+	_, err = client.UploadOrder(authCtx, &gophermartpb.UploadOrderRequest{Number: "123"})
+	assertGRPCError(t, err, codes.InvalidArgument, errs.CodeInvalidOrderNumber)
+
+	orders, err := client.ListOrders(authCtx, &gophermartpb.ListOrdersRequest{})
+	require.NoError(t, err)
+	require.Len(t, orders.Orders, 1)
+	assert.Equal(t, gophermartpb.OrderStatus_ORDER_STATUS_NEW, orders.Orders[0].Status)
+
+	_, err = client.Withdraw(authCtx, &gophermartpb.WithdrawRequest{Order: "2377225624", Sum: 50})
+	assertGRPCError(t, err, codes.FailedPrecondition, errs.CodeInsufficientBalance)
+
+	require.NoError(t, storage.CreditOrder(ctx, s.storage, "123456789049", 100))
+	_, err = client.Withdraw(authCtx, &gophermartpb.WithdrawRequest{Order: "2377225624", Sum: 50})
+	require.NoError(t, err)
+
+	balance, err := client.GetBalance(authCtx, &gophermartpb.GetBalanceRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, float64(50), balance.Current)
+	assert.Equal(t, float64(50), balance.Withdrawn)
+
+	withdrawals, err := client.ListWithdrawals(authCtx, &gophermartpb.ListWithdrawalsRequest{})
+	require.NoError(t, err)
+	require.Len(t, withdrawals.Withdrawals, 1)
+	assert.Equal(t, "2377225624", withdrawals.Withdrawals[0].Order)
+}
+
+func TestGRPCServer_WatchOrders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, s := newTestGRPCClient(t)
+
+	auth, err := client.Register(ctx, &gophermartpb.Credentials{Login: "user", Password: "pass"})
+	require.NoError(t, err)
+	authCtx := withToken(ctx, auth.Token)
+	_, err = client.UploadOrder(authCtx, &gophermartpb.UploadOrderRequest{Number: "123456789049"})
+	require.NoError(t, err)
+
+	stream, err := client.WatchOrders(authCtx, &gophermartpb.WatchOrdersRequest{})
+	require.NoError(t, err)
+
+	order, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "123456789049", order.Number)
+	assert.Equal(t, gophermartpb.OrderStatus_ORDER_STATUS_NEW, order.Status)
+
+	require.NoError(t, storage.CreditOrder(ctx, s.storage, "123456789049", 100))
+
+	order, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, gophermartpb.OrderStatus_ORDER_STATUS_PROCESSED, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, float64(100), *order.Accrual)
+}
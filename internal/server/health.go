@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"time"
+)
+
+// readyCheckTimeout время ожидания проверок бд в /readyz
+const readyCheckTimeout = 2 * time.Second
+
+const (
+	checkOK       = "ok"
+	checkFail     = "fail"
+	checkDegraded = "degraded" // checkDegraded сбой начислений: запросы пользователей обслуживаются, readyz отвечает 200
+	checkSkipped  = "skipped"  // checkSkipped проверка не поддерживается хранилищем или отключена
+)
+
+type healthJSON struct {
+	Status string                     `json:"status"`           // Итоговый статус: ok, degraded или fail
+	Checks map[string]healthCheckJSON `json:"checks,omitempty"` // Результаты проверок по зависимостям
+}
+
+type healthCheckJSON struct {
+	Status  string         `json:"status"`            // Статус проверки: ok, degraded, fail или skipped
+	Error   string         `json:"error,omitempty"`   // Причина неуспешной проверки
+	Details map[string]any `json:"details,omitempty"` // Подробности проверки
+}
+
+// healthzHandler процесс жив и обрабатывает запросы, зависимости не проверяются
+func healthzHandler(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, healthJSON{Status: checkOK})
+}
+
+// readyzHandler готовность принимать запросы: бд, версия схемы, система расчёта и пул воркеров.
+// 503 только при недоступной бд, необновлённой схеме и завершении сервера, сбой начислений
+// не мешает обслуживать пользователей и отдаётся статусом degraded с ответом 200
+func (s *Server) readyzHandler(ctx *fasthttp.RequestCtx) {
+	// контекст не наследует отмену RequestCtx, которая срабатывает при остановке fasthttp сервера
+	checkCtx, cancel := context.WithTimeout(logger.WithContext(context.Background(), logger.FromContext(ctx)), readyCheckTimeout)
+	defer cancel()
+
+	checks := map[string]healthCheckJSON{
+		"shutdown":   s.checkShutdown(),
+		"database":   s.checkDatabase(checkCtx),
+		"migrations": s.checkMigrations(checkCtx),
+		"accrual":    s.checkAccrual(),
+		"workers":    s.checkWorkers(),
+	}
+
+	result := healthJSON{Status: checkOK, Checks: checks}
+	for _, check := range checks {
+		switch {
+		case check.Status == checkFail:
+			result.Status = checkFail
+		case check.Status == checkDegraded && result.Status == checkOK:
+			result.Status = checkDegraded
+		}
+	}
+	writeJSON(ctx, result)
+	if result.Status == checkFail {
+		ctx.Response.SetStatusCode(fasthttp.StatusServiceUnavailable)
+	}
+}
+
+func (s *Server) checkShutdown() healthCheckJSON {
+	if s.draining.Load() {
+		return healthCheckJSON{Status: checkFail, Error: "сервер завершает работу"}
+	}
+	return healthCheckJSON{Status: checkOK}
+}
+
+func (s *Server) checkDatabase(ctx context.Context) healthCheckJSON {
+	start := time.Now()
+	err := storage.Ping(ctx, s.storage)
+	if errors.Is(err, storage.ErrNotSupported) {
+		return healthCheckJSON{Status: checkSkipped}
+	}
+	check := healthCheckJSON{Status: checkOK, Details: map[string]any{"latency": time.Since(start).String()}}
+	if err != nil {
+		check.Status = checkFail
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// checkMigrations схема не старше последней встроенной миграции, более новая схема допустима при обновлении инстансов
+func (s *Server) checkMigrations(ctx context.Context) healthCheckJSON {
+	current, latest, err := storage.SchemaVersion(ctx, s.storage)
+	if errors.Is(err, storage.ErrNotSupported) {
+		return healthCheckJSON{Status: checkSkipped}
+	}
+	if err != nil {
+		return healthCheckJSON{Status: checkFail, Error: err.Error()}
+	}
+	check := healthCheckJSON{Status: checkOK, Details: map[string]any{"current": current, "latest": latest}}
+	if current < latest {
+		check.Status = checkFail
+		check.Error = "схема бд не обновлена до последней версии"
+	}
+	return check
+}
+
+func (s *Server) checkAccrual() healthCheckJSON {
+	health := s.service.Health()
+	details := map[string]any{"consecutive_failures": health.ConsecutiveFailures}
+	if !health.LastSuccess.IsZero() {
+		details["last_success"] = health.LastSuccess.Format(time.RFC3339)
+	}
+	if health.Limit > 0 {
+		details["limit"] = health.Limit
+	}
+	check := healthCheckJSON{Status: checkOK, Details: details}
+	if !health.Reachable {
+		check.Status = checkDegraded
+		check.Error = health.LastError
+	}
+	return check
+}
+
+func (s *Server) checkWorkers() healthCheckJSON {
+	health := s.service.Health()
+	if !health.Running {
+		return healthCheckJSON{Status: checkDegraded, Error: "сервис начислений не запущен"}
+	}
+	check := healthCheckJSON{Status: checkOK, Details: map[string]any{
+		"workers":   health.Workers,
+		"alive":     health.WorkersAlive,
+		"generator": health.GeneratorAlive,
+	}}
+	switch {
+	case health.WorkersAlive < health.Workers:
+		check.Status = checkDegraded
+		check.Error = "работают не все воркеры"
+	case !health.GeneratorAlive:
+		check.Status = checkDegraded
+		check.Error = "выборка заказов для опроса остановлена"
+	}
+	return check
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/storage/sqlitestorage"
+	"github.com/valyala/fasthttp"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readyz(t *testing.T, s *Server) (int, healthJSON) {
+	ctx := createRequestWithBody("")
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI("/readyz")
+	s.readyzHandler(ctx)
+
+	var result healthJSON
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &result))
+	return ctx.Response.StatusCode(), result
+}
+
+func TestHealthzHandler(t *testing.T) {
+	ctx := createRequestWithBody("")
+	healthzHandler(ctx)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"status":"ok"}`, string(ctx.Response.Body()))
+}
+
+func TestReadyzHandler(t *testing.T) {
+	store, err := sqlitestorage.NewStorage(filepath.Join(t.TempDir(), "gophermart.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	s := New(&config.Config{}, store, accrual.Service{
+		Storage:      store,
+		Client:       accrual.NewMockClient(map[string][]accrual.ClientMockResponse{}),
+		PoolInterval: time.Hour,
+		Workers:      2,
+	})
+
+	// сбой начислений не снимает инстанс с балансировки
+	code, result := readyz(t, s)
+	assert.Equal(t, fasthttp.StatusOK, code, "сервис начислений не запущен")
+	assert.Equal(t, checkDegraded, result.Status)
+	assert.Equal(t, checkDegraded, result.Checks["workers"].Status)
+	assert.Equal(t, checkOK, result.Checks["database"].Status)
+	assert.Equal(t, checkOK, result.Checks["migrations"].Status)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.service.Run(ctx)
+	require.Eventually(t, func() bool {
+		_, result := readyz(t, s)
+		return result.Status == checkOK
+	}, time.Second, 10*time.Millisecond)
+	_, result = readyz(t, s)
+	assert.Equal(t, checkOK, result.Status)
+	for _, name := range []string{"shutdown", "database", "migrations", "accrual", "workers"} {
+		assert.Equal(t, checkOK, result.Checks[name].Status, name)
+	}
+
+	s.draining.Store(true)
+	code, result = readyz(t, s)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, code)
+	assert.Equal(t, checkFail, result.Checks["shutdown"].Status)
+	s.draining.Store(false)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, result := readyz(t, s)
+		return result.Status == checkDegraded
+	}, time.Second, 10*time.Millisecond, "воркеры остановлены")
+	code, result = readyz(t, s)
+	assert.Equal(t, fasthttp.StatusOK, code)
+	assert.Equal(t, checkDegraded, result.Checks["workers"].Status)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	fastRouter "github.com/fasthttp/router"
+	"github.com/google/uuid"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"time"
+)
+
+const (
+	requestIDUserValue = "requestID"
+	maxRequestIDLen    = 128
+)
+
+// newRequestID идентификатор запроса: входящий, если он допустим, иначе новый UUID
+func newRequestID(incoming string) string {
+	if validRequestID(incoming) {
+		return incoming
+	}
+	return uuid.NewString()
+}
+
+// validRequestID непустой идентификатор до maxRequestIDLen символов из букв, цифр и -_.:
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestLogMiddleware идентификатор запроса X-Request-ID в ответе и логгере запроса, строка журнала доступа по завершении.
+// Идёт первым в цепочке, чтобы журнал содержал итоговый статус и размер ответа
+func requestLogMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		id := newRequestID(string(ctx.Request.Header.Peek(requestIDHeader)))
+		ctx.SetUserValue(requestIDUserValue, id)
+		ctx.Response.Header.Set(requestIDHeader, id)
+		logger.Attach(ctx, logger.Log.With(logger.RequestIDKey, id))
+
+		next(ctx)
+
+		route, _ := ctx.UserValue(fastRouter.MatchedRoutePathParam).(string)
+		logger.FromContext(ctx).Infow("access",
+			"method", string(ctx.Method()),
+			"route", route,
+			"status", ctx.Response.StatusCode(),
+			"latency", time.Since(start),
+			"bytes", len(ctx.Response.Body()),
+		)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"strings"
+	"testing"
+)
+
+func TestRequestLogMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	prev := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	defer func() { logger.Log = prev }()
+
+	handler := requestLogMiddleware(func(ctx *fasthttp.RequestCtx) {
+		logger.FromContext(ctx).Info("handler")
+		ctx.SetStatusCode(fasthttp.StatusAccepted)
+		ctx.SetBodyString("ok")
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{"#1 incoming request id", "abc-123", true},
+		{"#2 generated request id", "", false},
+		{"#3 invalid incoming request id", "bad id\n", false},
+		{"#4 too long incoming request id", strings.Repeat("a", maxRequestIDLen+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := createRequestWithBody("")
+			ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+			if len(tt.incoming) > 0 {
+				ctx.Request.Header.Set(requestIDHeader, tt.incoming)
+			}
+			handler(ctx)
+
+			id := string(ctx.Response.Header.Peek(requestIDHeader))
+			require.NotEmpty(t, id)
+			assert.Equal(t, tt.wantSame, id == tt.incoming)
+			assert.Equal(t, id, requestID(ctx))
+
+			entries := logs.TakeAll()
+			require.Len(t, entries, 2)
+			assert.Equal(t, id, entries[0].ContextMap()[logger.RequestIDKey], "лог обработчика содержит идентификатор запроса")
+			access := entries[1].ContextMap()
+			assert.Equal(t, "access", entries[1].Message)
+			assert.Equal(t, id, access[logger.RequestIDKey])
+			assert.Equal(t, fasthttp.MethodPost, access["method"])
+			assert.Equal(t, int64(fasthttp.StatusAccepted), access["status"])
+			assert.Equal(t, int64(2), access["bytes"])
+		})
+	}
+}
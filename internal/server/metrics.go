@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	fastRouter "github.com/fasthttp/router"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"net"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute метка маршрута для запросов без зарегистрированного маршрута, чтобы путь не попадал в метки
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "количество HTTP запросов по маршруту и статусу ответа",
+	}, []string{"method", "route", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "длительность обработки HTTP запросов по маршруту",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(httpRequests, httpDuration)
+}
+
+// metricsMiddleware учёт количества и длительности запросов по маршруту, идёт первым в цепочке
+func metricsMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+
+		next(ctx)
+
+		route, ok := ctx.UserValue(fastRouter.MatchedRoutePathParam).(string)
+		if !ok {
+			route = unmatchedRoute
+		}
+		method := string(ctx.Method())
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Response.StatusCode())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// metricsHandler метрики metrics.Registry в формате Prometheus
+func metricsHandler() fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
+
+// ServeMetrics служебный сервер /metrics на отдельном listener до отмены appContext
+func ServeMetrics(appContext context.Context, ln net.Listener) error {
+	handler := metricsHandler()
+	srv := fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) != "/metrics" {
+			ctx.SetStatusCode(fasthttp.StatusNotFound)
+			return
+		}
+		handler(ctx)
+	}}
+
+	logger.Log.Info(fmt.Sprintf("Metrics server started at %s", ln.Addr().String()))
+
+	go func() {
+		<-appContext.Done()
+		if err := srv.Shutdown(); err != nil {
+			logger.Log.Errorf("metrics server shutdown error: %s", err.Error())
+		}
+	}()
+
+	return srv.Serve(ln)
+}
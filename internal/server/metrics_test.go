@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	s := New(&config.Config{}, memStorage, accrual.Service{})
+	router, err := s.newRouter()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantRoute string
+		wantCode  string
+	}{
+		{"#1 matched route", "GET", "/api/ping", "/api/ping", "200"},
+		{"#2 route with parameter", "POST", "/api/admin/orders/12345678903/requeue", "/api/admin/orders/{number}/requeue", "401"},
+		{"#3 unmatched route", "GET", "/api/unknown/12345", unmatchedRoute, "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(httpRequests.WithLabelValues(tt.method, tt.wantRoute, tt.wantCode))
+			ctx := createRequestWithBody("")
+			ctx.Request.Header.SetMethod(tt.method)
+			ctx.Request.SetRequestURI(tt.path)
+			router.Handler(ctx)
+
+			assert.Equal(t, before+1, testutil.ToFloat64(httpRequests.WithLabelValues(tt.method, tt.wantRoute, tt.wantCode)))
+		})
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = ServeMetrics(ctx, ln)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	httpRequests.WithLabelValues("GET", "/api/ping", "200").Inc()
+	res, err := http.Get("http://" + ln.Addr().String() + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	for _, name := range []string{"http_requests_total", "accrual_queue_depth", "accrual_workers_busy", "orders_uploaded_total", "withdrawal_points_total", "go_goroutines"} {
+		assert.Contains(t, string(body), name)
+	}
+
+	res, err = http.Get("http://" + ln.Addr().String() + "/api/ping")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "служебный сервер отдаёт только /metrics")
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"io"
+	"net/http"
+)
+
+// openAPIValidator проверка запросов и, в тестовом режиме, ответов по OpenAPI документу.
+// Запросы к роутам, которых нет в документе, не проверяются
+type openAPIValidator struct {
+	router            routers.Router
+	validateResponses bool
+	options           *openapi3filter.Options
+}
+
+func newOpenAPIValidator(spec []byte, validateResponses bool) (*openAPIValidator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения OpenAPI документа: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("неверный OpenAPI документ: %w", err)
+	}
+	// адрес servers в документе для клиентов, сервер принимает запросы на любом адресе
+	doc.Servers = nil
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения роутов OpenAPI: %w", err)
+	}
+	return &openAPIValidator{
+		router:            router,
+		validateResponses: validateResponses,
+		options: &openapi3filter.Options{
+			// токен проверяет authMiddleware, документ только описывает схему авторизации
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		},
+	}, nil
+}
+
+func (v *openAPIValidator) middleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		var req http.Request
+		if err := fasthttpadaptor.ConvertRequest(ctx, &req, true); err != nil {
+			writeError(ctx, fmt.Errorf("%w: %s", errs.ErrBadRequest, err.Error()))
+			return
+		}
+		route, pathParams, err := v.router.FindRoute(&req)
+		if err != nil {
+			next(ctx)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    &req,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    v.options,
+		}
+		if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+			logger.FromContext(ctx).Infof("запрос %s %s не соответствует api.yml: %s", ctx.Method(), ctx.Path(), err.Error())
+			writeError(ctx, fmt.Errorf("%w: %s", errs.ErrBadRequest, err.Error()))
+			return
+		}
+
+		next(ctx)
+
+		if v.validateResponses {
+			v.validateResponse(ctx, input)
+		}
+	}
+}
+
+// validateResponse замена ответа, не соответствующего документу, на 500, чтобы расхождение было видно в тестах
+func (v *openAPIValidator) validateResponse(ctx *fasthttp.RequestCtx, input *openapi3filter.RequestValidationInput) {
+	header := make(http.Header)
+	ctx.Response.Header.VisitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+	err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 ctx.Response.StatusCode(),
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(ctx.Response.Body())),
+		Options:                v.options,
+	})
+	if err != nil {
+		ctx.Response.ResetBody()
+		writeError(ctx, fmt.Errorf("ответ %s %s не соответствует api.yml: %w", ctx.Method(), ctx.Path(), err))
+	}
+}
+
+// openAPISpecHandler отдача OpenAPI документа
+func openAPISpecHandler(spec []byte) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("Content-Type", "application/yaml")
+		ctx.Response.SetStatusCode(fasthttp.StatusOK)
+		ctx.Response.SetBody(spec)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/api"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+func TestServer_openAPIValidation(t *testing.T) {
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	users := generateTestUsers(t, memStorage)
+	s := New(&config.Config{SecretKeyBytes: []byte("test"), OpenAPIValidateResponses: true}, memStorage, accrual.Service{})
+	router, err := s.newRouter()
+	require.NoError(t, err)
+	token, err := s.GetAuthToken(users[0])
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		auth        bool
+		wantStatus  int
+		wantCode    string
+	}{
+		{"#1 register without password", "POST", "/api/user/register", "application/json", `{"login":"new"}`, false, fasthttp.StatusBadRequest, errs.CodeBadRequest},
+		{"#2 register", "POST", "/api/user/register", "application/json", `{"login":"new","password":"pass"}`, false, fasthttp.StatusOK, ""},
+		{"#3 login wrong content type", "POST", "/api/user/login", "text/plain", `{"login":"user","password":"pass"}`, false, fasthttp.StatusBadRequest, errs.CodeBadRequest},
+		{"#4 negative withdrawal", "POST", "/api/user/balance/withdraw", "application/json", `{"order":"2377225624","sum":-10}`, true, fasthttp.StatusBadRequest, errs.CodeBadRequest},
+		{"#5 withdrawal without balance", "POST", "/api/user/balance/withdraw", "application/json", `{"order":"2377225624","sum":10}`, true, fasthttp.StatusPaymentRequired, errs.CodeInsufficientBalance},
+		{"#6 order as json", "POST", "/api/user/orders", "application/json", `"12345678903"`, true, fasthttp.StatusBadRequest, errs.CodeBadRequest},
+		{"#7 order", "POST", "/api/user/orders", "text/plain", "12345678903", true, fasthttp.StatusAccepted, ""},
+		{"#8 orders", "GET", "/api/user/orders", "", "", true, fasthttp.StatusOK, ""},
+		{"#9 invalid body without token", "POST", "/api/user/orders", "application/json", "{}", false, fasthttp.StatusUnauthorized, errs.CodeUnauthorized},
+		{"#10 route outside spec", "GET", "/api/ping", "", "", false, fasthttp.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := createRequestWithBodyAndContentType(tt.body, tt.contentType)
+			ctx.Request.Header.SetMethod(tt.method)
+			ctx.Request.SetRequestURI(tt.path)
+			if tt.auth {
+				ctx.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			}
+			router.Handler(ctx)
+
+			assert.Equal(t, tt.wantStatus, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+			if len(tt.wantCode) > 0 {
+				assert.Equal(t, tt.wantCode, decodeProblem(t, ctx).Code)
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidator_response(t *testing.T) {
+	validator, err := newOpenAPIValidator(api.Spec, true)
+	require.NoError(t, err)
+
+	handler := validator.middleware(func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("Content-Type", "application/json")
+		ctx.Response.SetBodyString(`{"current":"много"}`)
+	})
+	ctx := createRequestWithBody("")
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI("/api/user/balance")
+	handler(ctx)
+
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.Equal(t, errs.CodeInternal, decodeProblem(t, ctx).Code)
+}
+
+func TestServer_openAPISpec(t *testing.T) {
+	s := &Server{cfg: &config.Config{}}
+	router, err := s.newRouter()
+	require.NoError(t, err)
+
+	ctx := createRequestWithBody("")
+	ctx.Request.SetRequestURI("/api/openapi.yml")
+	router.Handler(ctx)
+
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "application/yaml", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, api.Spec, ctx.Response.Body())
+}
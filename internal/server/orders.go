@@ -3,10 +3,9 @@ package server
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
 	errs "github.com/superles/yapgofermart/internal/errors"
 	"github.com/superles/yapgofermart/internal/utils/logger"
-	"github.com/superles/yapgofermart/internal/utils/luna"
 	"github.com/valyala/fasthttp"
 	"time"
 )
@@ -21,72 +20,37 @@ type OrderJSON struct {
 func (s *Server) createOrderHandler(ctx *fasthttp.RequestCtx) {
 
 	contentType := ctx.Request.Header.ContentType()
-	userID, ok := ctx.UserValue("userID").(int64)
-	if !ok {
-		logger.Log.Errorf("ошибка получения пользователя из контекста")
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	}
 
 	if !bytes.Contains(contentType, []byte("text/plain")) {
-		logger.Log.Errorf("неверный формат запроса: %s", string(contentType))
-		ctx.Error("неверный формат запроса", fasthttp.StatusBadRequest)
+		logger.FromContext(ctx).Errorf("неверный формат запроса: %s", string(contentType))
+		writeError(ctx, errs.ErrBadRequest)
 		return
 	}
 
 	// Обработка создания заказа
 	body := ctx.Request.Body()
-	orderNumber := string(body)
 
-	if len(body) > 255 {
-		logger.Log.Errorf("номер заказа превысил длину: %s", orderNumber)
-		ctx.Error("неверный формат номера заказа", fasthttp.StatusUnprocessableEntity)
-		return
-	}
-	if isLunaValid, err := luna.Valid(orderNumber); err != nil {
-		logger.Log.Errorf("номер не соответствует алгоритму luna %s", err.Error())
-		ctx.Error("неверный формат номера заказа", fasthttp.StatusUnprocessableEntity)
-		return
-	} else if !isLunaValid {
-		logger.Log.Errorf("номер не соответствует алгоритму luna: %s", orderNumber)
-		ctx.Error("неверный формат номера заказа", fasthttp.StatusUnprocessableEntity)
+	created, err := s.orders.Upload(serviceContext(ctx), string(body))
+	if err != nil {
+		writeError(ctx, err)
 		return
 	}
 
-	err := s.storage.CreateNewOrder(ctx, orderNumber, userID)
-
-	if err == nil {
+	if created {
 		ctx.Response.SetStatusCode(202)
 		ctx.Response.SetBody(body)
 		return
 	}
 
-	if errors.Is(err, errs.ErrExistsAnotherUser) {
-		ctx.SetBodyString("номер заказа уже был загружен другим пользователем")
-		logger.Log.Infof("номер заказа уже был загружен другим пользователем: %s", orderNumber)
-		ctx.SetStatusCode(fasthttp.StatusConflict)
-	} else if errors.Is(err, errs.ErrExistsSameUser) {
-		ctx.SetBodyString("номер заказа уже был загружен этим пользователем")
-		logger.Log.Infof("номер заказа уже был загружен этим пользователем: %s", orderNumber)
-		ctx.SetStatusCode(fasthttp.StatusOK)
-	} else {
-		ctx.SetBodyString("ошибка сервера")
-		logger.Log.Errorf("ошибка сервера %s", err.Error())
-		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-	}
+	// повторная загрузка своего заказа по спецификации не ошибка, а ответ 200
+	ctx.SetBodyString(message(ctx, errs.CodeOrderExists))
+	ctx.SetStatusCode(fasthttp.StatusOK)
 }
 
 func (s *Server) getOrdersHandler(ctx *fasthttp.RequestCtx) {
-	userID, ok := ctx.UserValue("userID").(int64)
-	if !ok {
-		logger.Log.Errorf("ошибка получения пользователя из контекста")
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	}
-	orders, err := s.storage.GetAllOrdersByUser(ctx, userID)
-	if err != nil && !errors.Is(err, errs.ErrNoRows) {
-		logger.Log.Errorf("ошибка запроса заказов %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+	orders, err := s.orders.List(serviceContext(ctx))
+	if err != nil {
+		writeError(ctx, err)
 		return
 	}
 
@@ -105,8 +69,7 @@ func (s *Server) getOrdersHandler(ctx *fasthttp.RequestCtx) {
 		}
 	}
 	if data, err := json.Marshal(jsonOrders); err != nil {
-		logger.Log.Errorf("ошибка запроса сериализации %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+		writeError(ctx, fmt.Errorf("ошибка запроса сериализации: %w", err))
 	} else {
 		ctx.Response.Header.Set("Content-Type", "application/json")
 		ctx.Response.SetStatusCode(200)
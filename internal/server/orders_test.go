@@ -39,10 +39,7 @@ func TestServer_createOrderHandler(t *testing.T) {
 	users := generateTestUsers(t, memStorage)
 	user := users[0]
 
-	s := &Server{
-		cfg:     cfg,
-		storage: memStorage,
-	}
+	s := New(cfg, memStorage, accrual.Service{})
 
 	sum := float64(700)
 
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/i18n"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	requestIDHeader    = "X-Request-ID"
+	langUserValue      = "lang"
+)
+
+// Problem тело ответа с ошибкой по RFC 7807, Code - стабильный машиночитаемый код
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// requestID идентификатор запроса, назначенный requestLogMiddleware, без него - из заголовка X-Request-ID,
+// иначе порядковый номер запроса fasthttp
+func requestID(ctx *fasthttp.RequestCtx) string {
+	if id, ok := ctx.UserValue(requestIDUserValue).(string); ok {
+		return id
+	}
+	if id := ctx.Request.Header.Peek(requestIDHeader); len(id) > 0 {
+		return string(id)
+	}
+	return fmt.Sprintf("%d", ctx.ID())
+}
+
+// languageMiddleware выбор языка ответа по Accept-Language, def - язык, если клиент не указал поддерживаемый
+func languageMiddleware(def string) Middleware {
+	if !i18n.Supported(def) {
+		def = i18n.DefaultLanguage
+	}
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			lang := i18n.Negotiate(string(ctx.Request.Header.Peek(fasthttp.HeaderAcceptLanguage)), def)
+			ctx.SetUserValue(langUserValue, lang)
+			ctx.Response.Header.Set(fasthttp.HeaderContentLanguage, lang)
+			next(ctx)
+		}
+	}
+}
+
+// language язык ответа, выбранный languageMiddleware, без него - по заголовку и языку по умолчанию
+func language(ctx *fasthttp.RequestCtx) string {
+	if lang, ok := ctx.UserValue(langUserValue).(string); ok {
+		return lang
+	}
+	return i18n.Negotiate(string(ctx.Request.Header.Peek(fasthttp.HeaderAcceptLanguage)), i18n.DefaultLanguage)
+}
+
+// message сообщение для пользователя на языке ответа
+func message(ctx *fasthttp.RequestCtx, key string) string {
+	return i18n.Message(language(ctx), key)
+}
+
+// writeError ответ с ошибкой по сопоставлению из internal/errors с сообщением из каталога по коду.
+// Ошибки без сопоставления логируются и возвращаются как internal_error без подробностей
+func writeError(ctx *fasthttp.RequestCtx, err error) {
+	status, code, known := errs.Lookup(err)
+	if !known {
+		logger.FromContext(ctx).Errorf("ошибка сервера %s %s: %s", ctx.Method(), ctx.Path(), err.Error())
+	}
+	writeProblem(ctx, status, code, code)
+}
+
+// writeProblem ответ с ошибкой application/problem+json, detail - сообщение каталога по ключу key
+func writeProblem(ctx *fasthttp.RequestCtx, status int, code string, key string) {
+	detail := message(ctx, key)
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     fasthttp.StatusMessage(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  string(ctx.Path()),
+		Code:      code,
+		RequestID: requestID(ctx),
+	}
+	data, err := json.Marshal(problem)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("ошибка запроса сериализации %s", err.Error())
+		ctx.Error(detail, status)
+		return
+	}
+	ctx.Response.Header.Set("Content-Type", problemContentType)
+	ctx.Response.SetStatusCode(status)
+	ctx.Response.SetBody(data)
+}
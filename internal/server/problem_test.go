@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+func decodeProblem(t *testing.T, ctx *fasthttp.RequestCtx) Problem {
+	assert.Equal(t, problemContentType, string(ctx.Response.Header.ContentType()))
+	var problem Problem
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &problem))
+	assert.Equal(t, ctx.Response.StatusCode(), problem.Status)
+	return problem
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		lang       string
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{"#1 sentinel", errs.ErrInvalidOrderNumber, "", fasthttp.StatusUnprocessableEntity, errs.CodeInvalidOrderNumber, "неверный формат номера заказа"},
+		{"#2 wrapped", fmt.Errorf("заказ 1: %w", errs.ErrExistsAnotherUser), "", fasthttp.StatusConflict, errs.CodeOrderConflict, "номер заказа уже был загружен другим пользователем"},
+		{"#3 internal detail hidden", errors.New("pq: connection refused"), "", fasthttp.StatusInternalServerError, errs.CodeInternal, "ошибка сервера"},
+		{"#4 english", errs.ErrWithdrawalNotEnoughBalance, "en-US,en;q=0.9,ru;q=0.8", fasthttp.StatusPaymentRequired, errs.CodeInsufficientBalance, "insufficient balance"},
+		{"#5 unsupported language", errs.ErrForbidden, "de", fasthttp.StatusForbidden, errs.CodeForbidden, "доступ запрещён"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := createRequestWithBody("")
+			ctx.Request.SetRequestURI("/api/user/orders")
+			ctx.Request.Header.Set(requestIDHeader, "req-1")
+			ctx.Request.Header.Set(fasthttp.HeaderAcceptLanguage, tt.lang)
+			writeError(ctx, tt.err)
+
+			assert.Equal(t, tt.wantStatus, ctx.Response.StatusCode())
+			problem := decodeProblem(t, ctx)
+			assert.Equal(t, tt.wantCode, problem.Code)
+			assert.Equal(t, tt.wantDetail, problem.Detail)
+			assert.Equal(t, "/api/user/orders", problem.Instance)
+			assert.Equal(t, "req-1", problem.RequestID)
+		})
+	}
+}
+
+func TestServer_withdrawProblem(t *testing.T) {
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	users := generateTestUsers(t, memStorage)
+	s := New(&config.Config{}, memStorage, accrual.Service{})
+
+	ctx := createRequestWithBodyAndContentType(`{"order":"2377225624","sum":100}`, "application/json")
+	authCtxWithUser(ctx, users[0])
+	s.withdrawFromBalanceHandler(ctx)
+	assert.Equal(t, fasthttp.StatusPaymentRequired, ctx.Response.StatusCode())
+	assert.Equal(t, errs.CodeInsufficientBalance, decodeProblem(t, ctx).Code)
+}
+
+func TestLanguageMiddleware(t *testing.T) {
+	ctx := context.Background()
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	users := generateTestUsers(t, memStorage)
+	require.NoError(t, memStorage.CreateNewOrder(ctx, "12345678903", users[0].ID))
+	require.NoError(t, storage.CreditOrder(ctx, memStorage, "12345678903", 100))
+	s := New(&config.Config{}, memStorage, accrual.Service{})
+
+	tests := []struct {
+		name           string
+		def            string
+		acceptLanguage string
+		wantLanguage   string
+		wantBody       string
+	}{
+		{"#1 configured default", "en", "", "en", "withdrawal completed"},
+		{"#2 accept language", "en", "ru-RU", "ru", "списано успешно"},
+		{"#3 unsupported default", "fr", "", "ru", "списано успешно"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqCtx := createRequestWithBodyAndContentType(`{"order":"2377225624","sum":10}`, "application/json")
+			reqCtx.Request.Header.Set(fasthttp.HeaderAcceptLanguage, tt.acceptLanguage)
+			authCtxWithUser(reqCtx, users[0])
+			languageMiddleware(tt.def)(s.withdrawFromBalanceHandler)(reqCtx)
+
+			assert.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode())
+			assert.Equal(t, tt.wantLanguage, string(reqCtx.Response.Header.Peek(fasthttp.HeaderContentLanguage)))
+			assert.Equal(t, tt.wantBody, string(reqCtx.Response.Body()))
+		})
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	fastRouter "github.com/fasthttp/router"
+	"github.com/superles/yapgofermart/api"
 	"github.com/superles/yapgofermart/internal/accrual"
 	"github.com/superles/yapgofermart/internal/config"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/i18n"
+	"github.com/superles/yapgofermart/internal/service"
 	"github.com/superles/yapgofermart/internal/storage"
 	"github.com/superles/yapgofermart/internal/utils/logger"
 	"github.com/valyala/fasthttp"
 	"net"
+	"sync/atomic"
+	"time"
 )
 
 type Middleware func(h fasthttp.RequestHandler) fasthttp.RequestHandler
@@ -28,22 +34,47 @@ type Server struct {
 	cfg     *config.Config
 	storage storage.Storage
 	service accrual.Service
+	users   *service.UserService
+	orders  *service.OrderService
+	balance *service.BalanceService
+
+	draining atomic.Bool // draining сервер завершает работу, /readyz отвечает 503
 }
 
-func New(cfg *config.Config, s storage.Storage, service accrual.Service) *Server {
-	return &Server{cfg, s, service}
+func New(cfg *config.Config, s storage.Storage, accrualService accrual.Service) *Server {
+	return &Server{
+		cfg:     cfg,
+		storage: s,
+		service: accrualService,
+		users:   service.NewUserService(s),
+		orders:  service.NewOrderService(s),
+		balance: service.NewBalanceService(s),
+	}
 }
 
 func withCompressMiddleware(h fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return fasthttp.CompressHandler(h)
 }
 
-func (s *Server) newRouter() *fastRouter.Router {
+func (s *Server) newRouter() (*fastRouter.Router, error) {
+	validator, err := newOpenAPIValidator(api.Spec, s.cfg.OpenAPIValidateResponses)
+	if err != nil {
+		return nil, err
+	}
+
 	router := fastRouter.New()
-	withAuth := NewMiddleware([]Middleware{withCompressMiddleware, s.authMiddleware})
-	noAuth := NewMiddleware([]Middleware{withCompressMiddleware})
+	// маршрут нужен журналу доступа и метрикам
+	router.SaveMatchedRoutePath = true
+	withLanguage := languageMiddleware(s.cfg.DefaultLanguage)
+	// запрос проверяется по api.yml после авторизации, чтобы без токена всегда был ответ 401
+	withAuth := NewMiddleware([]Middleware{metricsMiddleware, requestLogMiddleware, tracingMiddleware, withCompressMiddleware, withLanguage, s.authMiddleware, validator.middleware})
+	noAuth := NewMiddleware([]Middleware{metricsMiddleware, requestLogMiddleware, tracingMiddleware, withCompressMiddleware, withLanguage, validator.middleware})
+	withAdmin := NewMiddleware([]Middleware{metricsMiddleware, requestLogMiddleware, tracingMiddleware, withCompressMiddleware, withLanguage, s.authMiddleware, s.adminMiddleware, validator.middleware})
 	//router.GET("/api/ping", withAuth(withCompress(pingHandler)))
 	router.GET("/api/ping", noAuth(pingHandler))
+	router.GET("/healthz", noAuth(healthzHandler))
+	router.GET("/readyz", noAuth(s.readyzHandler))
+	router.GET("/api/openapi.yml", noAuth(openAPISpecHandler(api.Spec)))
 	//router.GET("/api/ping", middleware(withAuth, withCompress, pingHandler))
 	router.POST("/api/user/register", noAuth(s.registerUserHandler))
 	router.POST("/api/user/login", noAuth(s.loginUserHandler))
@@ -52,7 +83,19 @@ func (s *Server) newRouter() *fastRouter.Router {
 	router.GET("/api/user/balance", withAuth(s.getUserBalanceHandler))
 	router.POST("/api/user/balance/withdraw", withAuth(s.withdrawFromBalanceHandler))
 	router.GET("/api/user/withdrawals", withAuth(s.getUserWithdrawalsHandler))
-	return router
+	router.GET("/api/admin/orders/stuck", withAdmin(s.getStuckOrdersHandler))
+	router.POST("/api/admin/orders/{number}/requeue", withAdmin(s.requeueStuckOrderHandler))
+	router.GET("/api/admin/orders/{number}/accrual-log", withAdmin(s.getOrderAccrualLogHandler))
+	router.GET("/api/admin/reconciliation", withAdmin(s.getReconciliationHandler))
+	router.NotFound = noAuth(func(ctx *fasthttp.RequestCtx) {
+		writeProblem(ctx, fasthttp.StatusNotFound, errs.CodeNotFound, i18n.MsgNotFoundRoute)
+	})
+	router.MethodNotAllowed = noAuth(func(ctx *fasthttp.RequestCtx) {
+		writeError(ctx, errs.ErrMethodNotAllowed)
+	})
+	s.registerLocalAccrualRoutes(router, withAdmin)
+	s.registerAccrualPushRoutes(router, noAuth)
+	return router, nil
 }
 
 func (s *Server) Run(appContext context.Context) error {
@@ -63,12 +106,48 @@ func (s *Server) Run(appContext context.Context) error {
 		return fmt.Errorf("адрес %s недоступен", s.cfg.Endpoint)
 	}
 
-	router := s.newRouter()
+	if len(s.cfg.MetricsEndpoint) > 0 {
+		metricsLn, err := net.Listen("tcp", s.cfg.MetricsEndpoint)
+		if err != nil {
+			_ = ln.Close()
+			return fmt.Errorf("адрес %s недоступен", s.cfg.MetricsEndpoint)
+		}
+		go func() {
+			if err := ServeMetrics(appContext, metricsLn); err != nil {
+				logger.Log.Errorf("ошибка сервера метрик: %s", err.Error())
+			}
+		}()
+	}
+
+	if len(s.cfg.GRPCEndpoint) > 0 {
+		grpcLn, err := net.Listen("tcp", s.cfg.GRPCEndpoint)
+		if err != nil {
+			_ = ln.Close()
+			return fmt.Errorf("адрес %s недоступен", s.cfg.GRPCEndpoint)
+		}
+		go func() {
+			if err := s.ServeGRPC(appContext, grpcLn); err != nil {
+				logger.Log.Errorf("ошибка gRPC сервера: %s", err.Error())
+			}
+		}()
+	}
+
+	return s.Serve(appContext, ln)
+}
+
+// Serve запуск сервера на готовом listener до отмены appContext, listener закрывается сервером
+func (s *Server) Serve(appContext context.Context, ln net.Listener) error {
+
+	router, err := s.newRouter()
+	if err != nil {
+		_ = ln.Close()
+		return err
+	}
 
 	srv := fasthttp.Server{}
 	srv.Handler = router.Handler
 
-	logger.Log.Info(fmt.Sprintf("Server started at %s", s.cfg.Endpoint))
+	logger.Log.Info(fmt.Sprintf("Server started at %s", ln.Addr().String()))
 
 	s.service.Run(appContext)
 
@@ -80,6 +159,13 @@ func (s *Server) Run(appContext context.Context) error {
 			logger.Log.Errorf("ошибка контескта: %s", appContext.Err())
 		}
 
+		// балансировщик успевает увидеть 503 в /readyz и перестать направлять запросы до закрытия listener
+		s.draining.Store(true)
+		if s.cfg.ShutdownDelay > 0 {
+			logger.Log.Infof("ожидание %s перед остановкой приёма запросов", s.cfg.ShutdownDelay)
+			time.Sleep(s.cfg.ShutdownDelay)
+		}
+
 		if err := srv.ShutdownWithContext(appContext); err != nil && err != context.Canceled {
 			logger.Log.Errorf("fasthttp server shutdown error: %s", err.Error())
 		}
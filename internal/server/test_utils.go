@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/service"
 	"github.com/superles/yapgofermart/internal/storage"
 	"github.com/valyala/fasthttp"
 	"testing"
@@ -33,7 +34,7 @@ func authCtxWithUser(ctx *fasthttp.RequestCtx, user model.User) {
 
 func generateTestUsers(t *testing.T, storage storage.Storage) []model.User {
 
-	salt, err := GenerateSalt()
+	salt, err := service.GenerateSalt()
 
 	if err != nil {
 		t.Fatalf("ошибка инициализации соли: %s", err.Error())
@@ -45,7 +46,7 @@ func generateTestUsers(t *testing.T, storage storage.Storage) []model.User {
 
 	regUser, err = storage.RegisterUser(context.Background(), model.User{
 		Name:         "user",
-		PasswordHash: HashPassword("pass", salt),
+		PasswordHash: service.HashPassword("pass", salt),
 	})
 
 	if err != nil {
@@ -56,7 +57,7 @@ func generateTestUsers(t *testing.T, storage storage.Storage) []model.User {
 
 	regUser, err = storage.RegisterUser(context.Background(), model.User{
 		Name:         "user1",
-		PasswordHash: HashPassword("pass1", salt),
+		PasswordHash: service.HashPassword("pass1", salt),
 	})
 
 	if err != nil {
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	fastRouter "github.com/fasthttp/router"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/tracing"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestHeaderCarrier заголовки запроса fasthttp для извлечения контекста трассировки
+type requestHeaderCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c requestHeaderCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c requestHeaderCarrier) Set(key string, value string) {
+	c.header.Set(key, value)
+}
+
+func (c requestHeaderCarrier) Keys() []string {
+	var keys []string
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// tracingMiddleware span запроса, продолжающий трассировку из traceparent входящего запроса.
+// Идёт после requestLogMiddleware, чтобы добавить trace_id в логгер запроса
+func tracingMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		parent := otel.GetTextMapPropagator().Extract(ctx, requestHeaderCarrier{&ctx.Request.Header})
+		route, ok := ctx.UserValue(fastRouter.MatchedRoutePathParam).(string)
+		if !ok {
+			route = unmatchedRoute
+		}
+		method := string(ctx.Method())
+		_, span := tracing.Tracer().Start(parent, fmt.Sprintf("%s %s", method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(string(ctx.Path())),
+			),
+		)
+		defer span.End()
+
+		tracing.Attach(ctx, span)
+		if traceID := tracing.TraceID(span); len(traceID) > 0 {
+			logger.Attach(ctx, logger.FromContext(ctx).With(logger.TraceIDKey, traceID))
+		}
+		if id, ok := ctx.UserValue(requestIDUserValue).(string); ok {
+			span.SetAttributes(attribute.String(logger.RequestIDKey, id))
+		}
+
+		next(ctx)
+
+		status := ctx.Response.StatusCode()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fasthttp.StatusInternalServerError {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(status))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/superles/yapgofermart/internal/storage/metricstorage"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	s := New(&config.Config{SecretKeyBytes: []byte("test")}, metricstorage.New(memStorage, 0), accrual.Service{})
+	router, err := s.newRouter()
+	require.NoError(t, err)
+
+	ctx := createRequestWithBodyAndContentType(`{"login":"traced","password":"pass"}`, "application/json")
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/api/user/register")
+	ctx.Request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.Handler(ctx)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, traceID, span.SpanContext().TraceID(), span.Name())
+		spans[span.Name()] = span
+	}
+
+	request, ok := spans["POST /api/user/register"]
+	require.True(t, ok, "нет span запроса")
+	assert.Equal(t, trace.SpanKindServer, request.SpanKind())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent().SpanID().String(), "span продолжает входящую трассировку")
+
+	storageSpan, ok := spans["storage.RegisterUser"]
+	require.True(t, ok, "нет span хранилища")
+	assert.Equal(t, request.SpanContext().SpanID(), storageSpan.Parent().SpanID())
+}
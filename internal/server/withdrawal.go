@@ -3,11 +3,11 @@ package server
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
 	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/i18n"
 	"github.com/superles/yapgofermart/internal/model"
 	"github.com/superles/yapgofermart/internal/utils/logger"
-	"github.com/superles/yapgofermart/internal/utils/luna"
 	"github.com/valyala/fasthttp"
 	"time"
 )
@@ -23,32 +23,17 @@ type balanceWithdrawRequest struct {
 }
 
 func (s *Server) getUserBalanceHandler(ctx *fasthttp.RequestCtx) {
-	userID, ok := ctx.UserValue("userID").(int64)
-	if !ok {
-		logger.Log.Errorf("ошибка получения пользователя из контекста")
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	}
-	withdrawnSum, err := s.storage.GetWithdrawnSumByUserID(ctx, userID)
-	if err != nil {
-		logger.Log.Errorf("ошибка получения суммы списаных баллов: %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	}
-
-	user, err := s.storage.GetUserByID(ctx, userID)
+	balance, err := s.balance.Balance(serviceContext(ctx))
 
 	if err != nil {
-		logger.Log.Errorf("ошибка получения пользователя %d: %s", userID, err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+		writeError(ctx, err)
 		return
 	}
 
-	response := balanceResponse{Current: user.Balance, Withdrawn: withdrawnSum}
+	response := balanceResponse{Current: balance.Current, Withdrawn: balance.Withdrawn}
 
 	if data, err := json.Marshal(response); err != nil {
-		logger.Log.Errorf("ошибка запроса сериализации %s", err.Error())
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+		writeError(ctx, fmt.Errorf("ошибка запроса сериализации: %w", err))
 	} else {
 		ctx.Response.Header.Set("Content-Type", "application/json")
 		ctx.Response.SetStatusCode(200)
@@ -58,15 +43,9 @@ func (s *Server) getUserBalanceHandler(ctx *fasthttp.RequestCtx) {
 
 func (s *Server) withdrawFromBalanceHandler(ctx *fasthttp.RequestCtx) {
 	contentType := ctx.Request.Header.ContentType()
-	userID, ok := ctx.UserValue("userID").(int64)
-	if !ok {
-		logger.Log.Errorf("ошибка получения пользователя из контекста")
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	}
 	if !bytes.Contains(contentType, []byte("application/json")) {
-		logger.Log.Errorf("неверный формат запроса: %s", string(contentType))
-		ctx.Error("неверный формат запроса", fasthttp.StatusBadRequest)
+		logger.FromContext(ctx).Errorf("неверный формат запроса: %s", string(contentType))
+		writeError(ctx, errs.ErrBadRequest)
 		return
 	}
 
@@ -75,61 +54,26 @@ func (s *Server) withdrawFromBalanceHandler(ctx *fasthttp.RequestCtx) {
 	err := json.Unmarshal(ctx.Request.Body(), &reqData)
 
 	if err != nil {
-		logger.Log.Errorf("ошибка декода запроса: %s", err.Error())
-		ctx.Error("неверный формат запроса", fasthttp.StatusBadRequest)
-		return
-	}
-
-	orderNumber := reqData.Order
-
-	if len(orderNumber) > 255 {
-		logger.Log.Errorf("номер заказа превысил длину: %s", orderNumber)
-		ctx.Error("неверный формат номера заказа", fasthttp.StatusUnprocessableEntity)
-		return
-	}
-	if isLunaValid, err := luna.Valid(orderNumber); err != nil {
-		logger.Log.Errorf("номер не соответствует алгоритму luna %s", err.Error())
-		ctx.Error("неверный формат номера заказа", fasthttp.StatusUnprocessableEntity)
-		return
-	} else if !isLunaValid {
-		logger.Log.Errorf("номер не соответствует алгоритму luna: %s", orderNumber)
-		ctx.Error("неверный формат номера заказа", fasthttp.StatusUnprocessableEntity)
+		logger.FromContext(ctx).Errorf("ошибка декода запроса: %s", err.Error())
+		writeError(ctx, errs.ErrBadRequest)
 		return
 	}
 
-	err = s.storage.CreateWithdrawal(ctx, orderNumber, reqData.Withdrawn, userID)
-
-	if err == nil {
-		ctx.SetBodyString("списано успешно")
-		logger.Log.Infof("успешно списано: заказ - %s, сумма - %f", orderNumber, reqData.Withdrawn)
-		ctx.SetStatusCode(fasthttp.StatusOK)
+	if err = s.balance.Withdraw(serviceContext(ctx), reqData.Order, reqData.Withdrawn); err != nil {
+		writeError(ctx, err)
 		return
 	}
 
-	if errors.Is(err, errs.ErrWithdrawalNotEnoughBalance) {
-		logger.Log.Error("на счету недостаточно средств")
-		ctx.Error("на счету недостаточно средств", fasthttp.StatusPaymentRequired)
-		return
-	}
-
-	logger.Log.Errorf("ошибка добавления списания средств: %s", err.Error())
-	ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+	ctx.SetBodyString(message(ctx, i18n.MsgWithdrawalDone))
+	ctx.SetStatusCode(fasthttp.StatusOK)
 }
 
 func (s *Server) getUserWithdrawalsHandler(ctx *fasthttp.RequestCtx) {
 
-	userID, ok := ctx.UserValue("userID").(int64)
-	if !ok {
-		logger.Log.Errorf("ошибка получения пользователя из контекста")
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
-		return
-	}
-
-	withdrawals, err := s.storage.GetAllWithdrawalsByUserID(ctx, userID)
+	withdrawals, err := s.balance.Withdrawals(serviceContext(ctx))
 
 	if err != nil {
-		logger.Log.Errorf("ошибка получения выводов средств, пользователь: %d", userID)
-		ctx.Error("ошибка сервера", fasthttp.StatusInternalServerError)
+		writeError(ctx, err)
 		return
 	}
 
@@ -150,8 +94,7 @@ func (s *Server) getUserWithdrawalsHandler(ctx *fasthttp.RequestCtx) {
 
 	jData, err := json.Marshal(outputData)
 	if err != nil {
-		logger.Log.Error(err)
-		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		writeError(ctx, fmt.Errorf("ошибка запроса сериализации: %w", err))
 		return
 	}
 	ctx.Response.Header.Set("Content-Type", "application/json")
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"math"
+)
+
+// Balance текущий баланс и сумма списаний пользователя
+type Balance struct {
+	Current   float64
+	Withdrawn float64
+}
+
+// BalanceStorage хранилище баланса: пользователи, списания и транзакции для списания
+type BalanceStorage interface {
+	storage.UserStorage
+	storage.WithdrawalStorage
+	storage.Transactor
+}
+
+// BalanceService баланс и списания пользователя из контекста
+type BalanceService struct {
+	storage BalanceStorage
+}
+
+func NewBalanceService(s BalanceStorage) *BalanceService {
+	return &BalanceService{storage: s}
+}
+
+// roundPoints округление суммы баллов до сотых, чтобы не отдавать накопленную погрешность float64
+func roundPoints(sum float64) float64 {
+	return math.Round(sum*100) / 100
+}
+
+// Balance баланс пользователя, сумма списаний хранится в пользователе и отдельный запрос не нужен
+func (s *BalanceService) Balance(ctx context.Context) (Balance, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return Balance{}, err
+	}
+	user, err := s.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return Balance{}, fmt.Errorf("ошибка получения пользователя %d: %w", userID, err)
+	}
+	return Balance{Current: roundPoints(user.Balance), Withdrawn: roundPoints(user.Withdrawn)}, nil
+}
+
+// Withdraw списание баллов в счёт заказа, недостаточно баллов - errs.ErrWithdrawalNotEnoughBalance
+func (s *BalanceService) Withdraw(ctx context.Context, orderNumber string, sum float64) error {
+	userID, err := userID(ctx)
+	if err != nil {
+		return err
+	}
+	if sum <= 0 {
+		return errs.ErrBadRequest
+	}
+	if err = ValidateOrderNumber(ctx, orderNumber); err != nil {
+		return err
+	}
+
+	err = storage.Withdraw(ctx, s.storage, orderNumber, sum, userID)
+
+	if err == nil {
+		withdrawalsTotal.Inc()
+		withdrawalPoints.Add(sum)
+		logger.FromContext(ctx).Infof("успешно списано: заказ - %s, сумма - %f", orderNumber, sum)
+		return nil
+	}
+
+	if errors.Is(err, errs.ErrWithdrawalNotEnoughBalance) {
+		logger.FromContext(ctx).Error("на счету недостаточно средств")
+		return err
+	}
+	return fmt.Errorf("ошибка добавления списания средств: %w", err)
+}
+
+// Withdrawals списания пользователя от новых к старым
+func (s *BalanceService) Withdrawals(ctx context.Context) ([]model.Withdrawal, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := s.storage.GetAllWithdrawalsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения выводов средств, пользователь %d: %w", userID, err)
+	}
+	return withdrawals, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"testing"
+)
+
+func TestBalanceService(t *testing.T) {
+	ctx := context.Background()
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	user, err := memStorage.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash"})
+	require.NoError(t, err)
+	require.NoError(t, memStorage.CreateNewOrder(ctx, "123456789049", user.ID))
+	require.NoError(t, storage.CreditOrder(ctx, memStorage, "123456789049", 100.1))
+
+	balance := NewBalanceService(memStorage)
+	userCtx := WithIdentity(ctx, Identity{UserID: user.ID})
+
+	tests := []struct {
+		name    string
+		order   string
+		sum     float64
+		wantErr error
+	}{
+		{"#1 zero sum", "2377225624", 0, errs.ErrBadRequest},
+		{"#2 invalid order number", "2377225625", 10, errs.ErrInvalidOrderNumber},
+		{"#3 not enough balance", "2377225624", 1000, errs.ErrWithdrawalNotEnoughBalance},
+		{"#4 withdraw", "2377225624", 50.2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, balance.Withdraw(userCtx, tt.order, tt.sum), tt.wantErr)
+		})
+	}
+
+	got, err := balance.Balance(userCtx)
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Current: 49.9, Withdrawn: 50.2}, got, "суммы округляются до сотых")
+
+	withdrawals, err := balance.Withdrawals(userCtx)
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, "2377225624", withdrawals[0].Order)
+
+	_, err = balance.Balance(ctx)
+	assert.ErrorIs(t, err, ErrNoIdentity)
+}
@@ -0,0 +1,39 @@
+// Package service бизнес-правила накопительной системы, не зависящие от транспорта: HTTP, gRPC, командная строка.
+// Пользователь, от имени которого выполняется операция, передаётся в контексте через WithIdentity
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoIdentity в контексте операции нет пользователя, транспорт не выполнил аутентификацию
+var ErrNoIdentity = errors.New("ошибка получения пользователя из контекста")
+
+// Identity аутентифицированный пользователь операции
+type Identity struct {
+	UserID int64
+	Name   string
+	Role   string
+}
+
+type identityKey struct{}
+
+// WithIdentity контекст операции от имени пользователя id
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext пользователь операции, установленный WithIdentity
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+func userID(ctx context.Context) (int64, error) {
+	id, ok := IdentityFromContext(ctx)
+	if !ok {
+		return 0, ErrNoIdentity
+	}
+	return id.UserID, nil
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+)
+
+var (
+	ordersUploaded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_uploaded_total",
+		Help: "количество принятых в обработку заказов",
+	})
+	withdrawalsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "withdrawals_total",
+		Help: "количество списаний баллов",
+	})
+	withdrawalPoints = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "withdrawal_points_total",
+		Help: "сумма списанных баллов",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(ordersUploaded, withdrawalsTotal, withdrawalPoints)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/luna"
+)
+
+// maxOrderNumberLen максимальная длина номера заказа
+const maxOrderNumberLen = 255
+
+// OrderService загрузка и просмотр заказов пользователя из контекста
+type OrderService struct {
+	storage storage.OrderStorage
+}
+
+func NewOrderService(s storage.OrderStorage) *OrderService {
+	return &OrderService{storage: s}
+}
+
+// ValidateOrderNumber проверка длины и контрольной суммы номера заказа по алгоритму Луна
+func ValidateOrderNumber(ctx context.Context, orderNumber string) error {
+	if len(orderNumber) > maxOrderNumberLen {
+		logger.FromContext(ctx).Errorf("номер заказа превысил длину: %s", orderNumber)
+		return errs.ErrInvalidOrderNumber
+	}
+	if isLunaValid, err := luna.Valid(orderNumber); err != nil {
+		logger.FromContext(ctx).Errorf("номер не соответствует алгоритму luna %s", err.Error())
+		return errs.ErrInvalidOrderNumber
+	} else if !isLunaValid {
+		logger.FromContext(ctx).Errorf("номер не соответствует алгоритму luna: %s", orderNumber)
+		return errs.ErrInvalidOrderNumber
+	}
+	return nil
+}
+
+// UserOrderStatus статус заказа для пользователя, STUCK не входит в API и отдаётся как PROCESSING
+func UserOrderStatus(status string) string {
+	if status == model.OrderStatusStuck {
+		return model.OrderStatusProcessing
+	}
+	return status
+}
+
+// Upload загрузка заказа, created - false, если заказ уже загружен этим пользователем.
+// Заказ другого пользователя - errs.ErrExistsAnotherUser
+func (s *OrderService) Upload(ctx context.Context, orderNumber string) (created bool, err error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err = ValidateOrderNumber(ctx, orderNumber); err != nil {
+		return false, err
+	}
+
+	err = s.storage.CreateNewOrder(ctx, orderNumber, userID)
+
+	if err == nil {
+		ordersUploaded.Inc()
+		return true, nil
+	}
+
+	if errors.Is(err, errs.ErrExistsSameUser) {
+		logger.FromContext(ctx).Infof("номер заказа уже был загружен этим пользователем: %s", orderNumber)
+		return false, nil
+	}
+	if errors.Is(err, errs.ErrExistsAnotherUser) {
+		logger.FromContext(ctx).Infof("номер заказа уже был загружен другим пользователем: %s", orderNumber)
+	}
+	return false, err
+}
+
+// List заказы пользователя от новых к старым со статусами для пользователя, без заказов - пустой список
+func (s *OrderService) List(ctx context.Context) ([]model.Order, error) {
+	userID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.storage.GetAllOrdersByUser(ctx, userID)
+	if err != nil && !errors.Is(err, errs.ErrNoRows) {
+		return nil, fmt.Errorf("ошибка запроса заказов: %w", err)
+	}
+	for i := range orders {
+		orders[i].Status = UserOrderStatus(orders[i].Status)
+	}
+	return orders, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"testing"
+)
+
+func TestOrderService_Upload(t *testing.T) {
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	orders := NewOrderService(memStorage)
+	user := WithIdentity(context.Background(), Identity{UserID: 1})
+	another := WithIdentity(context.Background(), Identity{UserID: 2})
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		number      string
+		wantCreated bool
+		wantErr     error
+	}{
+		{"#1 no identity", context.Background(), "123456789049", false, ErrNoIdentity},
+		{"#2 invalid luhn", user, "123456789040", false, errs.ErrInvalidOrderNumber},
+		{"#3 not a number", user, "12345abc", false, errs.ErrInvalidOrderNumber},
+		{"#4 created", user, "123456789049", true, nil},
+		{"#5 same user", user, "123456789049", false, nil},
+		{"#6 another user", another, "123456789049", false, errs.ErrExistsAnotherUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := orders.Upload(tt.ctx, tt.number)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantCreated, created)
+		})
+	}
+}
+
+func TestOrderService_List(t *testing.T) {
+	ctx := context.Background()
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	orders := NewOrderService(memStorage)
+	user := WithIdentity(ctx, Identity{UserID: 1})
+
+	list, err := orders.List(user)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	_, err = orders.Upload(user, "123456789049")
+	require.NoError(t, err)
+	require.NoError(t, memStorage.UpdateOrderStatus(ctx, "123456789049", model.OrderStatusStuck))
+
+	list, err = orders.List(user)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, model.OrderStatusProcessing, list[0].Status, "STUCK отдаётся пользователю как PROCESSING")
+}
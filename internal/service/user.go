@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+)
+
+// UserService регистрация и аутентификация пользователей
+type UserService struct {
+	storage storage.UserStorage
+}
+
+func NewUserService(s storage.UserStorage) *UserService {
+	return &UserService{storage: s}
+}
+
+// Register регистрация пользователя с ролью model.RoleUser, занятый логин - errs.ErrUserExists
+func (s *UserService) Register(ctx context.Context, login, password string) (model.User, error) {
+	user, err := s.storage.GetUserByName(ctx, login)
+
+	if len(user.Name) > 0 {
+		return model.User{}, errs.ErrUserExists
+	}
+
+	if err != nil && !errors.Is(err, errs.ErrNoRows) {
+		return model.User{}, fmt.Errorf("ошибка запроса пользователя: %w", err)
+	}
+
+	if len(login) == 0 || len(password) == 0 {
+		return model.User{}, errs.ErrBadRequest
+	}
+
+	passwordHash, err := HashPasswordWithRandomSalt(password)
+	if err != nil {
+		return model.User{}, fmt.Errorf("ошибка хеша пароля пользователя: %w", err)
+	}
+
+	regUser, err := s.storage.RegisterUser(ctx, model.User{Name: login, PasswordHash: passwordHash, Role: model.RoleUser})
+	if err != nil {
+		return model.User{}, fmt.Errorf("ошибка регистрации пользователя: %w", err)
+	}
+	return regUser, nil
+}
+
+// Login проверка логина и пароля, неизвестный пользователь или неверный пароль - errs.ErrInvalidCredentials
+func (s *UserService) Login(ctx context.Context, login, password string) (model.User, error) {
+	if len(login) == 0 || len(password) == 0 {
+		return model.User{}, errs.ErrBadRequest
+	}
+
+	user, err := s.storage.GetUserByName(ctx, login)
+	if err != nil {
+		if errors.Is(err, errs.ErrNoRows) {
+			logger.FromContext(ctx).Errorf("пользователь не найден %s", err.Error())
+			return model.User{}, errs.ErrInvalidCredentials
+		}
+		return model.User{}, fmt.Errorf("ошибка запроса пользователя: %w", err)
+	}
+
+	if isValid, err := ValidatePassword(user.PasswordHash, password); err != nil {
+		return model.User{}, fmt.Errorf("ошибка валидации пароля: %w", err)
+	} else if !isValid {
+		logger.FromContext(ctx).Errorf("неверный пароль")
+		return model.User{}, errs.ErrInvalidCredentials
+	}
+	return user, nil
+}
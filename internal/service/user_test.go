@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"testing"
+)
+
+func TestUserService(t *testing.T) {
+	ctx := context.Background()
+	memStorage, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	users := NewUserService(memStorage)
+
+	registered, err := users.Register(ctx, "user", "pass")
+	require.NoError(t, err)
+	assert.Equal(t, model.RoleUser, registered.Role)
+	assert.NotEqual(t, "pass", registered.PasswordHash)
+
+	tests := []struct {
+		name     string
+		register bool
+		login    string
+		password string
+		wantErr  error
+	}{
+		{"#1 register existing user", true, "user", "other", errs.ErrUserExists},
+		{"#2 register empty password", true, "user2", "", errs.ErrBadRequest},
+		{"#3 login", false, "user", "pass", nil},
+		{"#4 login wrong password", false, "user", "other", errs.ErrInvalidCredentials},
+		{"#5 login unknown user", false, "user2", "pass", errs.ErrInvalidCredentials},
+		{"#6 login empty login", false, "", "pass", errs.ErrBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.register {
+				_, err = users.Register(ctx, tt.login, tt.password)
+			} else {
+				var user model.User
+				user, err = users.Login(ctx, tt.login, tt.password)
+				if tt.wantErr == nil {
+					assert.Equal(t, registered.ID, user.ID)
+				}
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
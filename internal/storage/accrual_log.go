@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"context"
+	"github.com/superles/yapgofermart/internal/model"
+	"time"
+)
+
+type AccrualLogStorage interface {
+	AddAccrualLog(ctx context.Context, entry model.AccrualLog) error
+	GetAccrualLogsByOrder(ctx context.Context, number string) ([]model.AccrualLog, error)
+	GetRandomProcessedOrders(ctx context.Context, limit int) ([]model.Order, error)
+	DeleteOldAccrualLogs(ctx context.Context, maxAge time.Duration) (int64, error)
+}
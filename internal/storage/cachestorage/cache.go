@@ -0,0 +1,17 @@
+package cachestorage
+
+import (
+	"context"
+	"time"
+)
+
+// Cache хранилище значений по ключу с ограниченным временем жизни
+type Cache interface {
+	// Get значение по ключу, false если значения нет или оно устарело
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set запись значения на время ttl, при ttl 0 и меньше значение сразу устаревает и удаляется
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add запись значения на время ttl, только если актуального значения по ключу нет, false если значение не записано
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,178 @@
+package cachestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+	"io"
+	"time"
+)
+
+var (
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "storage_cache_hits_total",
+		Help: "количество чтений пользователя из кэша",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "storage_cache_misses_total",
+		Help: "количество чтений пользователя мимо кэша",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheHits, cacheMisses)
+}
+
+// tombstoneTTL время, в течение которого после начисления или списания пользователь не кэшируется,
+// должно быть больше времени чтения пользователя из хранилища
+const tombstoneTTL = 10 * time.Second
+
+// tombstone пустое значение на месте пользователя, изменённого транзакцией
+var tombstone = []byte{}
+
+// CachedStorage хранилище с кэшированием пользователя по id, включая баланс и сумму списаний.
+// После начисления и списания значение в кэше заменяется пустым на время tombstoneTTL,
+// а чтение кэширует пользователя только при отсутствии значения, поэтому баланс,
+// прочитанный из хранилища до записи, не попадёт в кэш после её сброса.
+// ttl ограничивает время, в течение которого виден баланс, изменённый в обход декоратора.
+// Хеш пароля в кэш не попадает и GetUserByID его не возвращает, проверка пароля идёт через GetUserByName без кэша
+type CachedStorage struct {
+	storage.Storage
+	cache Cache
+	ttl   time.Duration
+}
+
+func New(s storage.Storage, cache Cache, ttl time.Duration) *CachedStorage {
+	return &CachedStorage{Storage: s, cache: cache, ttl: ttl}
+}
+
+// cachedUser пользователь в кэше без хеша пароля
+type cachedUser struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Role      string  `json:"role"`
+	Balance   float64 `json:"balance"`
+	Withdrawn float64 `json:"withdrawn"`
+}
+
+func newCachedUser(user model.User) cachedUser {
+	return cachedUser{ID: user.ID, Name: user.Name, Role: user.Role, Balance: user.Balance, Withdrawn: user.Withdrawn}
+}
+
+func (u cachedUser) user() model.User {
+	return model.User{ID: u.ID, Name: u.Name, Role: u.Role, Balance: u.Balance, Withdrawn: u.Withdrawn}
+}
+
+func userKey(id int64) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+func (s *CachedStorage) GetUserByID(ctx context.Context, id int64) (model.User, error) {
+	key := userKey(id)
+	data, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		// недоступный кэш не должен ломать запросы, чтение идёт напрямую в хранилище
+		logger.FromContext(ctx).Warnf("ошибка чтения кэша %s: %s", key, err.Error())
+	}
+	// пустое значение - пользователь недавно изменён, кэш не используется до его истечения
+	if ok && len(data) > 0 {
+		var cached cachedUser
+		if err := json.Unmarshal(data, &cached); err == nil {
+			cacheHits.Inc()
+			return cached.user(), nil
+		}
+	}
+	cacheMisses.Inc()
+
+	user, err := s.Storage.GetUserByID(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	cached := newCachedUser(user)
+	if data, err := json.Marshal(cached); err == nil {
+		if _, err := s.cache.Add(ctx, key, data, s.ttl); err != nil {
+			logger.FromContext(ctx).Warnf("ошибка записи кэша %s: %s", key, err.Error())
+		}
+	}
+	// хеш не возвращается и при промахе, чтобы ответ не зависел от состояния кэша
+	return cached.user(), nil
+}
+
+// GetWithdrawnSumByUserID сумма списаний из кэшированного пользователя
+func (s *CachedStorage) GetWithdrawnSumByUserID(ctx context.Context, userID int64) (float64, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if errors.Is(err, errs.ErrNoRows) {
+		return 0, nil
+	}
+	return user.Withdrawn, err
+}
+
+// WithTx выполнение транзакции со сбросом кэша пользователей, изменённых в ней
+func (s *CachedStorage) WithTx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	var touched []int64
+	err := s.Storage.WithTx(ctx, func(tx storage.Tx) error {
+		touched = touched[:0]
+		return fn(&trackingTx{Tx: tx, touched: &touched})
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx, touched...)
+	return nil
+}
+
+func (s *CachedStorage) invalidate(ctx context.Context, userIDs ...int64) {
+	for _, id := range userIDs {
+		key := userKey(id)
+		if err := s.cache.Set(ctx, key, tombstone, tombstoneTTL); err != nil {
+			logger.FromContext(ctx).Errorf("ошибка сброса кэша %s: %s", key, err.Error())
+		}
+	}
+}
+
+// Close закрытие кэша и хранилища, если они это поддерживают
+func (s *CachedStorage) Close() error {
+	var err error
+	if closer, ok := s.cache.(io.Closer); ok {
+		err = closer.Close()
+	}
+	if closer, ok := s.Storage.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Ping проверка соединения хранилища, если оно это поддерживает
+func (s *CachedStorage) Ping(ctx context.Context) error {
+	return storage.Ping(ctx, s.Storage)
+}
+
+// SchemaVersion версия схемы хранилища, если оно это поддерживает
+func (s *CachedStorage) SchemaVersion(ctx context.Context) (int64, int64, error) {
+	return storage.SchemaVersion(ctx, s.Storage)
+}
+
+// trackingTx учёт пользователей, изменённых в транзакции
+type trackingTx struct {
+	storage.Tx
+	touched *[]int64
+}
+
+func (t *trackingTx) UpdateUserBalance(ctx context.Context, id int64, balance float64) error {
+	*t.touched = append(*t.touched, id)
+	return t.Tx.UpdateUserBalance(ctx, id, balance)
+}
+
+func (t *trackingTx) AddWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	*t.touched = append(*t.touched, withdrawal.UserID)
+	return t.Tx.AddWithdrawal(ctx, withdrawal)
+}
@@ -0,0 +1,215 @@
+package cachestorage
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/superles/yapgofermart/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestConformanceLRU(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := memstorage.NewStorage()
+		require.NoError(t, err)
+		return New(s, NewLRU(100), time.Minute)
+	})
+}
+
+func TestConformanceRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		server.FlushAll()
+		s, err := memstorage.NewStorage()
+		require.NoError(t, err)
+		cache, err := NewRedisCache(context.Background(), server.Addr())
+		require.NoError(t, err)
+		cached := New(s, cache, time.Minute)
+		t.Cleanup(func() {
+			require.NoError(t, cached.Close())
+		})
+		return cached
+	})
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memstorage.NewStorage()
+	require.NoError(t, err)
+	cache := NewLRU(10)
+	s := New(inner, cache, time.Minute)
+
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+
+	_, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cache.Len(), "пользователь попал в кэш")
+	data, ok, err := cache.Get(ctx, userKey(user.ID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.NotContains(t, string(data), "hash", "хеш пароля не хранится в кэше")
+	byName, err := s.GetUserByName(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", byName.PasswordHash, "вход проверяет пароль мимо кэша")
+
+	hits := testutil.ToFloat64(cacheHits)
+	_, err = s.GetWithdrawnSumByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, hits+1, testutil.ToFloat64(cacheHits), "сумма списаний читается из кэша")
+
+	// изменение в обход декоратора не видно до истечения ttl
+	require.NoError(t, storage.CreditOrder(ctx, inner, "12345678903", 100))
+	cached, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, cached.Balance)
+
+	require.NoError(t, storage.Withdraw(ctx, s, "2377225624", 30, user.ID))
+	data, ok, err = cache.Get(ctx, userKey(user.ID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, data, "списание сбрасывает кэш")
+
+	misses := testutil.ToFloat64(cacheMisses)
+	cached, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(70), cached.Balance)
+	assert.Equal(t, float64(30), cached.Withdrawn)
+	_, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, misses+2, testutil.ToFloat64(cacheMisses), "изменённый пользователь не кэшируется")
+}
+
+// racingStorage хранилище, выполняющее запись между чтением пользователя и его записью в кэш
+type racingStorage struct {
+	storage.Storage
+	write func()
+}
+
+func (s *racingStorage) GetUserByID(ctx context.Context, id int64) (model.User, error) {
+	user, err := s.Storage.GetUserByID(ctx, id)
+	if s.write != nil {
+		write := s.write
+		s.write = nil
+		write()
+	}
+	return user, err
+}
+
+func TestCachedStorageRace(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memstorage.NewStorage()
+	require.NoError(t, err)
+	racing := &racingStorage{Storage: inner}
+	s := New(racing, NewLRU(10), time.Minute)
+
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+
+	racing.write = func() {
+		require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+	}
+	stale, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, stale.Balance)
+
+	cached, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), cached.Balance, "баланс, прочитанный до начисления, не попал в кэш")
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRU(2)
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := cache.Get(ctx, "a")
+	assert.True(t, ok)
+
+	// вытесняется b, к которому обращались раньше всего
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Minute))
+	_, ok, _ = cache.Get(ctx, "b")
+	assert.False(t, ok)
+	value, ok, _ := cache.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	require.NoError(t, cache.Set(ctx, "d", []byte("4"), time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	_, ok, _ = cache.Get(ctx, "d")
+	assert.False(t, ok, "устаревшее значение")
+
+	require.NoError(t, cache.Set(ctx, "e", []byte("5"), 0))
+	_, ok, _ = cache.Get(ctx, "e")
+	assert.False(t, ok, "нулевой ttl")
+
+	added, err := cache.Add(ctx, "a", []byte("6"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, added, "актуальное значение не перезаписывается")
+	added, err = cache.Add(ctx, "d", []byte("7"), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, added, "устаревшее значение перезаписывается")
+	value, _, _ = cache.Get(ctx, "a")
+	assert.Equal(t, []byte("1"), value)
+
+	require.NoError(t, cache.Delete(ctx, "a", "unknown"))
+	_, ok, _ = cache.Get(ctx, "a")
+	assert.False(t, ok)
+}
+
+func TestRedisCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache, err := NewRedisCache(ctx, server.Addr())
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Second))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), 0))
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), -time.Second))
+	server.FastForward(time.Minute)
+
+	_, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok, "устаревшее значение")
+	_, ok, err = cache.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, ok, "нулевой ttl")
+	_, ok, err = cache.Get(ctx, "c")
+	require.NoError(t, err)
+	assert.False(t, ok, "отрицательный ttl")
+}
+
+func TestRedisCacheAdd(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache, err := NewRedisCache(ctx, server.Addr())
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.NoError(t, cache.Set(ctx, "a", []byte{}, time.Second))
+	added, err := cache.Add(ctx, "a", []byte("1"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, added, "пустое значение не перезаписывается")
+	value, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, value)
+
+	server.FastForward(2 * time.Second)
+	added, err = cache.Add(ctx, "a", []byte("1"), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, added, "устаревшее значение перезаписывается")
+	value, _, err = cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"github.com/superles/yapgofermart/internal/model"
+)
+
+// DumpStorage выгрузка и загрузка всех данных с сохранением идентификаторов, балансов и времени.
+// Export вызывает fn для каждой записи и останавливается на первой ошибке fn
+type DumpStorage interface {
+	ExportUsers(ctx context.Context, fn func(user model.User) error) error
+	ExportOrders(ctx context.Context, fn func(order model.Order) error) error
+	ExportWithdrawals(ctx context.Context, fn func(withdrawal model.Withdrawal) error) error
+	ImportUser(ctx context.Context, user model.User) error
+	ImportOrder(ctx context.Context, order model.Order) error
+	// ImportWithdrawal запись списания без изменения баланса, он переносится вместе с пользователем.
+	// Списание того же пользователя по тому же заказу с тем же временем не записывается повторно - errs.ErrWithdrawalExists
+	ImportWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotSupported проверка не поддерживается хранилищем
+var ErrNotSupported = errors.New("не поддерживается хранилищем")
+
+// Pinger проверка соединения с бд
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// SchemaVersioner версия схемы бд: применённая и последняя встроенная в бинарник
+type SchemaVersioner interface {
+	SchemaVersion(ctx context.Context) (current int64, latest int64, err error)
+}
+
+// Ping проверка соединения хранилища s, ErrNotSupported если хранилище не реализует Pinger
+func Ping(ctx context.Context, s any) error {
+	if pinger, ok := s.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return ErrNotSupported
+}
+
+// SchemaVersion версия схемы хранилища s, ErrNotSupported если хранилище не реализует SchemaVersioner
+func SchemaVersion(ctx context.Context, s any) (int64, int64, error) {
+	if versioner, ok := s.(SchemaVersioner); ok {
+		return versioner.SchemaVersion(ctx)
+	}
+	return 0, 0, ErrNotSupported
+}
@@ -0,0 +1,82 @@
+package memstorage
+
+import (
+	"context"
+	"github.com/superles/yapgofermart/internal/model"
+	"math/rand"
+	"time"
+)
+
+// AddAccrualLog запись ответа системы расчёта в журнал
+func (s *MemStorage) AddAccrualLog(ctx context.Context, entry model.AccrualLog) error {
+	s.accrualLogSync.Lock()
+	defer s.accrualLogSync.Unlock()
+	entry.ID = 1
+	if len(s.accrualLogs) > 0 {
+		entry.ID = s.accrualLogs[len(s.accrualLogs)-1].ID + 1
+	}
+	entry.CreatedAt = time.Now()
+	if err := s.persist(walRecord{AccrualLog: &entry}); err != nil {
+		return err
+	}
+	s.accrualLogs = append(s.accrualLogs, entry)
+	return nil
+}
+
+func (s *MemStorage) GetAccrualLogsByOrder(ctx context.Context, number string) ([]model.AccrualLog, error) {
+	s.accrualLogSync.RLock()
+	defer s.accrualLogSync.RUnlock()
+	var newCollection []model.AccrualLog
+	for _, entry := range s.accrualLogs {
+		if entry.Order == number {
+			newCollection = append(newCollection, entry)
+		}
+	}
+	return newCollection, nil
+}
+
+// DeleteOldAccrualLogs удаление записей журнала ответов системы расчёта старше maxAge
+func (s *MemStorage) DeleteOldAccrualLogs(ctx context.Context, maxAge time.Duration) (int64, error) {
+	s.accrualLogSync.Lock()
+	defer s.accrualLogSync.Unlock()
+	before := time.Now().Add(-maxAge)
+	if err := s.persist(walRecord{AccrualLogsBefore: &before}); err != nil {
+		return 0, err
+	}
+	count := len(s.accrualLogs)
+	s.deleteAccrualLogsBefore(before)
+	return int64(count - len(s.accrualLogs)), nil
+}
+
+// deleteAccrualLogsBefore удаление записей журнала, полученных раньше before
+func (s *MemStorage) deleteAccrualLogsBefore(before time.Time) {
+	kept := s.accrualLogs[:0]
+	for _, entry := range s.accrualLogs {
+		if !entry.CreatedAt.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	s.accrualLogs = kept
+}
+
+// GetRandomProcessedOrders случайная выборка заказов в статусе PROCESSED для сверки с системой расчёта
+func (s *MemStorage) GetRandomProcessedOrders(ctx context.Context, limit int) ([]model.Order, error) {
+	s.orderSync.RLock()
+	defer s.orderSync.RUnlock()
+	var newCollection []model.Order
+	for _, order := range s.orders {
+		if order.Status == model.OrderStatusProcessed {
+			newCollection = append(newCollection, order)
+		}
+	}
+
+	rand.Shuffle(len(newCollection), func(i, j int) {
+		newCollection[i], newCollection[j] = newCollection[j], newCollection[i]
+	})
+
+	if len(newCollection) > limit {
+		newCollection = newCollection[:limit]
+	}
+
+	return newCollection, nil
+}
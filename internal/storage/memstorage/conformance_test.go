@@ -0,0 +1,27 @@
+package memstorage
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/storagetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := NewStorage()
+		require.NoError(t, err)
+		return s
+	})
+}
+
+func TestPersistentConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := NewPersistentStorage(t.TempDir(), 0)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, s.Close())
+		})
+		return s
+	})
+}
@@ -4,18 +4,37 @@ import (
 	"github.com/superles/yapgofermart/internal/model"
 	"github.com/superles/yapgofermart/internal/storage"
 	"sync"
+	"time"
 )
 
-var userStorageSync = sync.RWMutex{}
-var orderStorageSync = sync.RWMutex{}
-var withdrawStorageSync = sync.RWMutex{}
-
+// MemStorage хранилище в памяти. Блокировки берутся в порядке orders, users, withdraws, accrualLogs, wal
 type MemStorage struct {
-	users     []model.User
-	orders    []model.Order
-	withdraws []model.Withdrawal
+	userSync       sync.RWMutex
+	orderSync      sync.RWMutex
+	withdrawSync   sync.RWMutex
+	accrualLogSync sync.RWMutex
+
+	users       []model.User
+	orders      []model.Order
+	withdraws   []model.Withdrawal
+	queuedAt    map[string]time.Time // queuedAt время возврата заказа в очередь опроса
+	accrualLogs []model.AccrualLog
+
+	wal *wal // wal журнал изменений, nil - хранилище без сохранения на диск
 }
 
 func NewStorage() (storage.Storage, error) {
-	return &MemStorage{}, nil
+	return newMemStorage(), nil
+}
+
+func newMemStorage() *MemStorage {
+	return &MemStorage{queuedAt: make(map[string]time.Time)}
+}
+
+// persist запись изменения в журнал до его применения в памяти, без журнала ничего не делает
+func (s *MemStorage) persist(records ...walRecord) error {
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.append(records...)
 }
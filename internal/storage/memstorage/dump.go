@@ -0,0 +1,90 @@
+package memstorage
+
+import (
+	"context"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+)
+
+func (s *MemStorage) ExportUsers(ctx context.Context, fn func(user model.User) error) error {
+	s.userSync.RLock()
+	users := append([]model.User(nil), s.users...)
+	s.userSync.RUnlock()
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemStorage) ExportOrders(ctx context.Context, fn func(order model.Order) error) error {
+	s.orderSync.RLock()
+	orders := append([]model.Order(nil), s.orders...)
+	s.orderSync.RUnlock()
+	for _, order := range orders {
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemStorage) ExportWithdrawals(ctx context.Context, fn func(withdrawal model.Withdrawal) error) error {
+	s.withdrawSync.RLock()
+	withdrawals := append([]model.Withdrawal(nil), s.withdraws...)
+	s.withdrawSync.RUnlock()
+	for _, withdrawal := range withdrawals {
+		if err := fn(withdrawal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemStorage) ImportUser(ctx context.Context, user model.User) error {
+	s.userSync.Lock()
+	defer s.userSync.Unlock()
+	for _, existing := range s.users {
+		if existing.ID == user.ID || existing.Name == user.Name {
+			return errs.ErrUserExists
+		}
+	}
+	if err := s.persist(walRecord{User: &user}); err != nil {
+		return err
+	}
+	s.users = append(s.users, user)
+	return nil
+}
+
+func (s *MemStorage) ImportOrder(ctx context.Context, order model.Order) error {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
+	if idx := s.findOrder(order.Number); idx >= 0 {
+		if s.orders[idx].UserID != order.UserID {
+			return errs.ErrExistsAnotherUser
+		}
+		return errs.ErrExistsSameUser
+	}
+	if err := s.persist(walRecord{Order: &order}); err != nil {
+		return err
+	}
+	s.orders = append(s.orders, order)
+	return nil
+}
+
+func (s *MemStorage) ImportWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	s.withdrawSync.Lock()
+	defer s.withdrawSync.Unlock()
+	for _, existing := range s.withdraws {
+		if existing.UserID == withdrawal.UserID && existing.Order == withdrawal.Order && existing.ProcessedAt.Equal(withdrawal.ProcessedAt) {
+			return errs.ErrWithdrawalExists
+		}
+	}
+	record := withdrawalRecord{UserID: withdrawal.UserID, Order: withdrawal.Order, Sum: withdrawal.Sum, ProcessedAt: withdrawal.ProcessedAt}
+	if err := s.persist(walRecord{Withdrawal: &record}); err != nil {
+		return err
+	}
+	s.withdraws = append(s.withdraws, withdrawal)
+	return nil
+}
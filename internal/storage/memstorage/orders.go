@@ -2,8 +2,6 @@ package memstorage
 
 import (
 	"context"
-	"errors"
-	_ "github.com/jackc/pgx/v5/stdlib"
 	errs "github.com/superles/yapgofermart/internal/errors"
 	"github.com/superles/yapgofermart/internal/model"
 	"sort"
@@ -11,30 +9,42 @@ import (
 )
 
 func (s *MemStorage) GetOrder(ctx context.Context, number string) (model.Order, error) {
-	orderStorageSync.RLock()
-	defer orderStorageSync.RUnlock()
-	for _, order := range s.orders {
-		if order.Number == number {
-			return order, nil
-		}
+	s.orderSync.RLock()
+	defer s.orderSync.RUnlock()
+	if idx := s.findOrder(number); idx >= 0 {
+		return s.orders[idx], nil
 	}
 
 	return model.Order{}, errs.ErrNoRows
 }
 
-func sortOrderByUploadedAt(orders []model.Order) {
+// findOrder индекс заказа в s.orders, -1 если не найден, вызывается под блокировкой orderSync
+func (s *MemStorage) findOrder(number string) int {
+	for idx, order := range s.orders {
+		if order.Number == number {
+			return idx
+		}
+	}
+	return -1
+}
+
+// sortOrderByUploadedAt сортировка от старых к новым, desc - от новых к старым
+func sortOrderByUploadedAt(orders []model.Order, desc bool) {
 	// Определение функции Less для интерфейса sort.Interface
 	lessFunc := func(i, j int) bool {
+		if desc {
+			return orders[i].UploadedAt.After(orders[j].UploadedAt)
+		}
 		return orders[i].UploadedAt.Before(orders[j].UploadedAt)
 	}
 
-	// Использование sort.Slice для сортировки
-	sort.Slice(orders, lessFunc)
+	// Использование sort.SliceStable для сортировки
+	sort.SliceStable(orders, lessFunc)
 }
 
 func (s *MemStorage) GetAllOrdersByUser(ctx context.Context, userID int64) ([]model.Order, error) {
-	orderStorageSync.RLock()
-	defer orderStorageSync.RUnlock()
+	s.orderSync.RLock()
+	defer s.orderSync.RUnlock()
 	var newCollection []model.Order
 	for _, order := range s.orders {
 		if order.UserID == userID {
@@ -42,15 +52,15 @@ func (s *MemStorage) GetAllOrdersByUser(ctx context.Context, userID int64) ([]mo
 		}
 	}
 
-	sortOrderByUploadedAt(newCollection)
+	sortOrderByUploadedAt(newCollection, true)
 
 	return newCollection, nil
 }
 
 // GetAllNewAndProcessingOrders получение всех заказов со статусами NEW и PROCESSING для запроса/повторного запроса в системе лояльности(accrual)
 func (s *MemStorage) GetAllNewAndProcessingOrders(ctx context.Context) ([]model.Order, error) {
-	orderStorageSync.RLock()
-	defer orderStorageSync.RUnlock()
+	s.orderSync.RLock()
+	defer s.orderSync.RUnlock()
 	var newCollection []model.Order
 	for _, order := range s.orders {
 		if order.Status == model.OrderStatusNew || order.Status == model.OrderStatusProcessing {
@@ -58,98 +68,129 @@ func (s *MemStorage) GetAllNewAndProcessingOrders(ctx context.Context) ([]model.
 		}
 	}
 
-	sortOrderByUploadedAt(newCollection)
+	sortOrderByUploadedAt(newCollection, false)
 
 	return newCollection, nil
 }
 
 func (s *MemStorage) CreateNewOrder(ctx context.Context, number string, userID int64) error {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
 
-	order, err := s.GetOrder(ctx, number)
-
-	if err != nil && !errors.Is(err, errs.ErrNoRows) {
-		return err
-	}
-
-	if len(order.Number) > 0 && order.UserID != userID {
-		//Если существует и пользователь не совпадает
-		return errs.ErrExistsAnotherUser
-	} else if len(order.Number) > 0 && order.UserID == userID {
+	if idx := s.findOrder(number); idx >= 0 {
+		if s.orders[idx].UserID != userID {
+			//Если существует и пользователь не совпадает
+			return errs.ErrExistsAnotherUser
+		}
 		//Если существует и пользователь совпадает
 		return errs.ErrExistsSameUser
 	}
 
-	orderStorageSync.Lock()
-	s.orders = append(s.orders, model.Order{Number: number, UserID: userID, Status: model.OrderStatusNew, UploadedAt: time.Now()})
-	orderStorageSync.Unlock()
+	order := model.Order{Number: number, UserID: userID, Status: model.OrderStatusNew, UploadedAt: time.Now()}
+	if err := s.persist(walRecord{Order: &order}); err != nil {
+		return err
+	}
+	s.orders = append(s.orders, order)
 
 	return nil
 }
 
-func (s *MemStorage) UpdateOrderStatus(ctx context.Context, number string, status string) error {
-	orderStorageSync.Lock()
-	defer orderStorageSync.Unlock()
-	for idx, order := range s.orders {
-		if order.Number == number {
-			order.Status = status
-			s.orders[idx] = order
-			return nil
-		}
+// updateOrder изменение заказа с записью в журнал, вызывается под блокировкой orderSync
+func (s *MemStorage) updateOrder(idx int, update func(order *model.Order)) error {
+	order := s.orders[idx]
+	update(&order)
+	if err := s.persist(walRecord{Order: &order}); err != nil {
+		return err
 	}
-	return errs.ErrNoRows
+	s.orders[idx] = order
+	return nil
 }
 
-func (s *MemStorage) SetOrderProcessedAndUserBalance(ctx context.Context, number string, sum float64) error {
-
-	if sum < 0 {
-		return errors.New("невозможно начислить отрицательную сумму в качестве бонусов")
+func (s *MemStorage) UpdateOrderStatus(ctx context.Context, number string, status string) error {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
+	idx := s.findOrder(number)
+	if idx < 0 {
+		return errs.ErrNoRows
 	}
-
-	orderStorageSync.Lock()
-	defer orderStorageSync.Unlock()
-	userStorageSync.Lock()
-	defer userStorageSync.Unlock()
-
-	var updateOrder model.Order
-	var updateOrderIndex int
-
-	for idx, order := range s.orders {
-		if order.Number == number {
-			updateOrder = order
-			updateOrderIndex = idx
-			break
-		}
+	if current := s.orders[idx].Status; current == model.OrderStatusProcessed || current == model.OrderStatusInvalid {
+		return errs.ErrOrderFinal
 	}
+	return s.updateOrder(idx, func(order *model.Order) {
+		order.Status = status
+	})
+}
 
-	if len(updateOrder.Number) == 0 {
+// IncrementOrderAttempts учёт очередного запроса заказа в систему расчёта
+func (s *MemStorage) IncrementOrderAttempts(ctx context.Context, number string) error {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
+	idx := s.findOrder(number)
+	if idx < 0 {
 		return errs.ErrNoRows
 	}
+	return s.updateOrder(idx, func(order *model.Order) {
+		order.Attempts++
+	})
+}
 
-	if updateOrder.Status == model.OrderStatusProcessed || updateOrder.Status == model.OrderStatusInvalid {
-		return errs.ErrNoRows
+// MarkStuckOrders перевод в статус STUCK заказов NEW и PROCESSING, ожидающих дольше maxAge или опрошенных не менее maxAttempts раз, нулевое значение отключает критерий
+func (s *MemStorage) MarkStuckOrders(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error) {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
+	var count int64
+	for idx, order := range s.orders {
+		if order.Status != model.OrderStatusNew && order.Status != model.OrderStatusProcessing {
+			continue
+		}
+		queuedAt, ok := s.queuedAt[order.Number]
+		if !ok {
+			queuedAt = order.UploadedAt
+		}
+		if (maxAge > 0 && time.Since(queuedAt) > maxAge) || (maxAttempts > 0 && order.Attempts >= maxAttempts) {
+			if err := s.updateOrder(idx, func(order *model.Order) {
+				order.Status = model.OrderStatusStuck
+			}); err != nil {
+				return count, err
+			}
+			count++
+		}
 	}
+	return count, nil
+}
 
-	var updateUser model.User
-	var updateUserIndex int
-
-	for idx, user := range s.users {
-		if user.ID == updateOrder.UserID {
-			updateUser = user
-			updateUserIndex = idx
-			break
+func (s *MemStorage) GetAllStuckOrders(ctx context.Context) ([]model.Order, error) {
+	s.orderSync.RLock()
+	defer s.orderSync.RUnlock()
+	var newCollection []model.Order
+	for _, order := range s.orders {
+		if order.Status == model.OrderStatusStuck {
+			newCollection = append(newCollection, order)
 		}
 	}
 
-	if len(updateUser.Name) == 0 {
-		return errs.ErrNoRows
-	}
+	sortOrderByUploadedAt(newCollection, false)
 
-	updateOrder.Status = model.OrderStatusProcessed
-	updateOrder.Accrual = &sum
-	s.orders[updateOrderIndex] = updateOrder
+	return newCollection, nil
+}
 
-	updateUser.Balance += sum
-	s.users[updateUserIndex] = updateUser
+// RequeueStuckOrder возврат заказа из статуса STUCK в очередь опроса со сбросом попыток
+func (s *MemStorage) RequeueStuckOrder(ctx context.Context, number string) error {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
+	idx := s.findOrder(number)
+	if idx < 0 || s.orders[idx].Status != model.OrderStatusStuck {
+		return errs.ErrNoRows
+	}
 
+	order := s.orders[idx]
+	order.Status = model.OrderStatusNew
+	order.Attempts = 0
+	queued := queuedRecord{Number: number, At: time.Now()}
+	if err := s.persist(walRecord{Order: &order, Queued: &queued}); err != nil {
+		return err
+	}
+	s.orders[idx] = order
+	s.queuedAt[number] = queued.At
 	return nil
 }
@@ -0,0 +1,118 @@
+package memstorage
+
+import (
+	"context"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"time"
+)
+
+// memTx транзакция в памяти: изменения копятся в tx и применяются при фиксации,
+// на время транзакции удерживаются блокировки orders, users и withdraws на запись
+type memTx struct {
+	s           *MemStorage
+	users       map[int64]model.User
+	orders      map[string]model.Order
+	withdrawals []withdrawalRecord
+}
+
+func (s *MemStorage) WithTx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	s.orderSync.Lock()
+	defer s.orderSync.Unlock()
+	s.userSync.Lock()
+	defer s.userSync.Unlock()
+	s.withdrawSync.Lock()
+	defer s.withdrawSync.Unlock()
+
+	tx := &memTx{s: s, users: make(map[int64]model.User), orders: make(map[string]model.Order)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// commit запись изменений транзакции одной записью журнала и применение в памяти
+func (t *memTx) commit() error {
+	var record walRecord
+	for _, user := range t.users {
+		user := user
+		record.Tx = append(record.Tx, walRecord{User: &user})
+	}
+	for _, order := range t.orders {
+		order := order
+		record.Tx = append(record.Tx, walRecord{Order: &order})
+	}
+	for _, withdrawal := range t.withdrawals {
+		withdrawal := withdrawal
+		record.Tx = append(record.Tx, walRecord{Withdrawal: &withdrawal})
+	}
+	if len(record.Tx) == 0 {
+		return nil
+	}
+
+	if err := t.s.persist(record); err != nil {
+		return err
+	}
+	t.s.apply(record)
+	return nil
+}
+
+func (t *memTx) GetUserForUpdate(ctx context.Context, id int64) (model.User, error) {
+	if user, ok := t.users[id]; ok {
+		return user, nil
+	}
+	for _, user := range t.s.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return model.User{}, errs.ErrNoRows
+}
+
+func (t *memTx) GetOrderForUpdate(ctx context.Context, number string) (model.Order, error) {
+	if order, ok := t.orders[number]; ok {
+		return order, nil
+	}
+	if idx := t.s.findOrder(number); idx >= 0 {
+		return t.s.orders[idx], nil
+	}
+	return model.Order{}, errs.ErrNoRows
+}
+
+func (t *memTx) UpdateUserBalance(ctx context.Context, id int64, balance float64) error {
+	user, err := t.GetUserForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+	user.Balance = balance
+	t.users[id] = user
+	return nil
+}
+
+func (t *memTx) UpdateOrder(ctx context.Context, order model.Order) error {
+	current, err := t.GetOrderForUpdate(ctx, order.Number)
+	if err != nil {
+		return err
+	}
+	current.Status = order.Status
+	current.Accrual = order.Accrual
+	current.Attempts = order.Attempts
+	t.orders[order.Number] = current
+	return nil
+}
+
+func (t *memTx) AddWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	processedAt := withdrawal.ProcessedAt
+	if processedAt.IsZero() {
+		processedAt = time.Now()
+	}
+	user, err := t.GetUserForUpdate(ctx, withdrawal.UserID)
+	if err != nil {
+		return err
+	}
+	user.Withdrawn += withdrawal.Sum
+	t.users[user.ID] = user
+	t.withdrawals = append(t.withdrawals, withdrawalRecord{UserID: withdrawal.UserID, Order: withdrawal.Order, Sum: withdrawal.Sum, ProcessedAt: processedAt})
+	return nil
+}
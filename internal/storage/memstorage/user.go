@@ -2,14 +2,13 @@ package memstorage
 
 import (
 	"context"
-	_ "github.com/jackc/pgx/v5/stdlib"
 	errs "github.com/superles/yapgofermart/internal/errors"
 	"github.com/superles/yapgofermart/internal/model"
 )
 
 func (s *MemStorage) GetUserByID(ctx context.Context, id int64) (model.User, error) {
-	userStorageSync.RLock()
-	defer userStorageSync.RUnlock()
+	s.userSync.RLock()
+	defer s.userSync.RUnlock()
 	for _, user := range s.users {
 		if user.ID == id {
 			return user, nil
@@ -20,8 +19,8 @@ func (s *MemStorage) GetUserByID(ctx context.Context, id int64) (model.User, err
 }
 
 func (s *MemStorage) GetUserByName(ctx context.Context, name string) (model.User, error) {
-	userStorageSync.RLock()
-	defer userStorageSync.RUnlock()
+	s.userSync.RLock()
+	defer s.userSync.RUnlock()
 	for _, user := range s.users {
 		if user.Name == name {
 			return user, nil
@@ -32,9 +31,25 @@ func (s *MemStorage) GetUserByName(ctx context.Context, name string) (model.User
 }
 
 func (s *MemStorage) RegisterUser(ctx context.Context, data model.User) (model.User, error) {
-	userStorageSync.Lock()
-	data.ID = int64(len(s.users) + 1)
+	s.userSync.Lock()
+	for _, user := range s.users {
+		if user.Name == data.Name {
+			s.userSync.Unlock()
+			return data, errs.ErrUserExists
+		}
+	}
+	// идентификаторы импортированных пользователей могут идти с пропусками
+	data.ID = 1
+	for _, user := range s.users {
+		if user.ID >= data.ID {
+			data.ID = user.ID + 1
+		}
+	}
+	if err := s.persist(walRecord{User: &data}); err != nil {
+		s.userSync.Unlock()
+		return data, err
+	}
 	s.users = append(s.users, data)
-	userStorageSync.Unlock()
+	s.userSync.Unlock()
 	return s.GetUserByName(ctx, data.Name)
 }
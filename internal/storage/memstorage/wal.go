@@ -0,0 +1,353 @@
+package memstorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+)
+
+// withdrawalRecord списание в журнале, model.Withdrawal не сериализует UserID
+type withdrawalRecord struct {
+	UserID      int64     `json:"user_id"`
+	Order       string    `json:"order"`
+	Sum         float64   `json:"sum"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+type queuedRecord struct {
+	Number string    `json:"number"`
+	At     time.Time `json:"at"`
+}
+
+// walRecord запись журнала: новое состояние одной сущности
+type walRecord struct {
+	Seq               int64             `json:"seq,omitempty"` // Seq номер записи журнала, 0 у вложенных записей Tx и журналов старого формата
+	User              *model.User       `json:"user,omitempty"`
+	Order             *model.Order      `json:"order,omitempty"`
+	Queued            *queuedRecord     `json:"queued,omitempty"`
+	Withdrawal        *withdrawalRecord `json:"withdrawal,omitempty"`
+	AccrualLog        *model.AccrualLog `json:"accrual_log,omitempty"`
+	AccrualLogsBefore *time.Time        `json:"accrual_logs_before,omitempty"` // AccrualLogsBefore удаление записей журнала ответов системы расчёта, полученных раньше
+	Tx                []walRecord       `json:"tx,omitempty"`                  // Tx записи одной транзакции, применяются вместе
+}
+
+// snapshot полное состояние хранилища
+type snapshot struct {
+	Seq         int64                `json:"seq"` // Seq номер последней записи журнала, вошедшей в снимок
+	Users       []model.User         `json:"users"`
+	Orders      []model.Order        `json:"orders"`
+	QueuedAt    map[string]time.Time `json:"queued_at"`
+	Withdrawals []withdrawalRecord   `json:"withdrawals"`
+	AccrualLogs []model.AccrualLog   `json:"accrual_logs"`
+}
+
+// wal журнал изменений в файле dir/wal.log, периодически сжимается в dir/snapshot.json
+type wal struct {
+	mu   sync.Mutex
+	dir  string
+	file *os.File
+	seq  int64 // seq номер последней записанной записи
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// append запись в журнал с присвоением записям очередных номеров
+func (w *wal) append(records ...walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return errors.New("журнал хранилища закрыт")
+	}
+
+	var buf bytes.Buffer
+	seq := w.seq
+	for _, record := range records {
+		seq++
+		record.Seq = seq
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("ошибка записи журнала: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.seq = seq
+	return nil
+}
+
+// NewPersistentStorage хранилище в памяти с сохранением в каталог dir: состояние восстанавливается
+// из снимка и журнала, журнал сжимается в снимок каждые compactInterval (0 - только при закрытии)
+func NewPersistentStorage(dir string, compactInterval time.Duration) (*MemStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := newMemStorage()
+	seq, err := s.loadSnapshot(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		return nil, err
+	}
+	if seq, err = s.replay(filepath.Join(dir, walFile), seq); err != nil {
+		return nil, err
+	}
+	s.recountWithdrawn()
+
+	file, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = &wal{dir: dir, file: file, seq: seq, done: make(chan struct{})}
+
+	// сжатие сразу после восстановления отбрасывает возможную оборванную запись в конце журнала
+	if err := s.Compact(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if compactInterval > 0 {
+		s.wal.wg.Add(1)
+		go s.compactLoop(compactInterval)
+	}
+
+	return s, nil
+}
+
+func (s *MemStorage) compactLoop(interval time.Duration) {
+	defer s.wal.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.wal.done:
+			return
+		case <-ticker.C:
+			if err := s.Compact(); err != nil {
+				logger.Log.Errorf("ошибка сжатия журнала хранилища: %s", err.Error())
+			}
+		}
+	}
+}
+
+// loadSnapshot загрузка снимка, возвращает номер последней вошедшей в него записи журнала
+func (s *MemStorage) loadSnapshot(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("ошибка чтения снимка хранилища: %w", err)
+	}
+	s.users = snap.Users
+	s.orders = snap.Orders
+	s.accrualLogs = snap.AccrualLogs
+	for number, at := range snap.QueuedAt {
+		s.queuedAt[number] = at
+	}
+	for _, w := range snap.Withdrawals {
+		s.withdraws = append(s.withdraws, model.Withdrawal{UserID: w.UserID, Order: w.Order, Sum: w.Sum, ProcessedAt: w.ProcessedAt})
+	}
+	return snap.Seq, nil
+}
+
+// replay применение записей журнала поверх снимка, оборванная последняя запись пропускается.
+// Записи с номером не больше snapshotSeq уже вошли в снимок: журнал остаётся целиком, если процесс упал
+// между заменой снимка и очисткой журнала. Возвращает номер последней записи
+func (s *MemStorage) replay(path string, snapshotSeq int64) (int64, error) {
+	seq := snapshotSeq
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return seq, nil
+	}
+	if err != nil {
+		return seq, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				logger.Log.Warnf("пропущена оборванная запись журнала хранилища: %s", string(line))
+			}
+			return seq, nil
+		}
+		if err != nil {
+			return seq, err
+		}
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return seq, fmt.Errorf("ошибка чтения журнала хранилища: %w", err)
+		}
+		// записи старого формата без номера применяются всегда
+		if record.Seq > 0 && record.Seq <= snapshotSeq {
+			continue
+		}
+		s.apply(record)
+		if record.Seq > seq {
+			seq = record.Seq
+		}
+	}
+}
+
+// apply применение записи журнала к состоянию в памяти
+func (s *MemStorage) apply(record walRecord) {
+	if record.User != nil {
+		s.upsertUser(*record.User)
+	}
+	if record.Order != nil {
+		s.upsertOrder(*record.Order)
+	}
+	if record.Queued != nil {
+		s.queuedAt[record.Queued.Number] = record.Queued.At
+	}
+	if record.Withdrawal != nil {
+		w := record.Withdrawal
+		s.withdraws = append(s.withdraws, model.Withdrawal{UserID: w.UserID, Order: w.Order, Sum: w.Sum, ProcessedAt: w.ProcessedAt})
+	}
+	if record.AccrualLog != nil {
+		s.accrualLogs = append(s.accrualLogs, *record.AccrualLog)
+	}
+	if record.AccrualLogsBefore != nil {
+		s.deleteAccrualLogsBefore(*record.AccrualLogsBefore)
+	}
+	for _, nested := range record.Tx {
+		s.apply(nested)
+	}
+}
+
+func (s *MemStorage) upsertUser(user model.User) {
+	for idx := range s.users {
+		if s.users[idx].ID == user.ID {
+			s.users[idx] = user
+			return
+		}
+	}
+	s.users = append(s.users, user)
+}
+
+func (s *MemStorage) upsertOrder(order model.Order) {
+	for idx := range s.orders {
+		if s.orders[idx].Number == order.Number {
+			s.orders[idx] = order
+			return
+		}
+	}
+	s.orders = append(s.orders, order)
+}
+
+// recountWithdrawn пересчёт сумм списаний пользователей, снимки старого формата их не содержат
+func (s *MemStorage) recountWithdrawn() {
+	withdrawn := make(map[int64]float64)
+	for _, w := range s.withdraws {
+		withdrawn[w.UserID] += w.Sum
+	}
+	for idx := range s.users {
+		s.users[idx].Withdrawn = withdrawn[s.users[idx].ID]
+	}
+}
+
+// Compact запись снимка состояния и очистка журнала
+func (s *MemStorage) Compact() error {
+	if s.wal == nil {
+		return nil
+	}
+
+	s.orderSync.RLock()
+	defer s.orderSync.RUnlock()
+	s.userSync.RLock()
+	defer s.userSync.RUnlock()
+	s.withdrawSync.RLock()
+	defer s.withdrawSync.RUnlock()
+	s.accrualLogSync.RLock()
+	defer s.accrualLogSync.RUnlock()
+	s.wal.mu.Lock()
+	defer s.wal.mu.Unlock()
+	if s.wal.file == nil {
+		return errors.New("журнал хранилища закрыт")
+	}
+
+	// записи журнала применяются к памяти под блокировками, взятыми выше, поэтому снимок содержит все записи до seq
+	snap := snapshot{Seq: s.wal.seq, Users: s.users, Orders: s.orders, QueuedAt: s.queuedAt, AccrualLogs: s.accrualLogs}
+	for _, w := range s.withdraws {
+		snap.Withdrawals = append(snap.Withdrawals, withdrawalRecord{UserID: w.UserID, Order: w.Order, Sum: w.Sum, ProcessedAt: w.ProcessedAt})
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	// снимок пишется во временный файл и атомарно заменяет предыдущий
+	tmp := filepath.Join(s.wal.dir, snapshotFile+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.wal.dir, snapshotFile)); err != nil {
+		return err
+	}
+
+	if err := s.wal.file.Truncate(0); err != nil {
+		return err
+	}
+	return s.wal.file.Sync()
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Close остановка периодического сжатия, запись снимка и закрытие журнала
+func (s *MemStorage) Close() error {
+	if s.wal == nil {
+		return nil
+	}
+	close(s.wal.done)
+	s.wal.wg.Wait()
+
+	err := s.Compact()
+
+	s.wal.mu.Lock()
+	defer s.wal.mu.Unlock()
+	if closeErr := s.wal.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.wal.file = nil
+	return err
+}
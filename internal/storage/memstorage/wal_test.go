@@ -0,0 +1,160 @@
+package memstorage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistentStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewPersistentStorage(dir, 0)
+	require.NoError(t, err, "ошибка инициализации хранилища")
+
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+	require.NoError(t, s.CreateNewOrder(ctx, "2377225624", user.ID))
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+	require.NoError(t, storage.Withdraw(ctx, s, "79927398713", 30, user.ID))
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "2377225624", HTTPCode: 500, Error: "internal server error"}))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "12345678903", Status: "PROCESSED", HTTPCode: 200}))
+	count, err := s.DeleteOldAccrualLogs(ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// состояние без сжатия восстанавливается только из журнала
+	restored, err := NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	assertRestored(t, restored, user.ID)
+	logs, err := restored.GetAccrualLogsByOrder(ctx, "2377225624")
+	require.NoError(t, err)
+	assert.Empty(t, logs, "удалённые записи журнала не восстанавливаются")
+	require.NoError(t, restored.Close())
+
+	require.NoError(t, s.Close())
+	assert.ErrorContains(t, s.CreateNewOrder(ctx, "4561261212345467", user.ID), "журнал хранилища закрыт")
+
+	// после закрытия состояние восстанавливается из снимка
+	restored, err = NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+	assertRestored(t, restored, user.ID)
+	logs, err = restored.GetAccrualLogsByOrder(ctx, "2377225624")
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+	assert.ErrorIs(t, restored.CreateNewOrder(ctx, "12345678903", user.ID), errs.ErrExistsSameUser)
+}
+
+func assertRestored(t *testing.T, s *MemStorage, userID int64) {
+	ctx := context.Background()
+
+	user, err := s.GetUserByName(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", user.PasswordHash)
+	assert.Equal(t, float64(70), user.Balance)
+
+	order, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusProcessed, order.Status)
+	assert.Equal(t, userID, order.UserID)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, float64(100), *order.Accrual)
+
+	orders, err := s.GetAllNewAndProcessingOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "2377225624", orders[0].Number)
+
+	withdrawn, err := s.GetWithdrawnSumByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(30), withdrawn)
+
+	logs, err := s.GetAccrualLogsByOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
+
+func TestPersistentStorageTruncatedWal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+
+	// имитация падения процесса во время записи последней записи журнала
+	file, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"order":{"number":"2377225624","sta`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	restored, err := NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	_, err = restored.GetOrder(ctx, "12345678903")
+	assert.NoError(t, err)
+	_, err = restored.GetOrder(ctx, "2377225624")
+	assert.ErrorIs(t, err, errs.ErrNoRows, "оборванная запись не применяется")
+
+	require.NoError(t, restored.CreateNewOrder(ctx, "2377225624", user.ID), "журнал после восстановления пишется с чистой строки")
+	info, err := os.Stat(filepath.Join(dir, walFile))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+}
+
+func TestPersistentStorageCompactCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+	require.NoError(t, s.CreateNewOrder(ctx, "2377225624", user.ID))
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+	require.NoError(t, storage.Withdraw(ctx, s, "79927398713", 30, user.ID))
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "12345678903", Status: "PROCESSED", HTTPCode: 200}))
+
+	walPath := filepath.Join(dir, walFile)
+	journal, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// имитация падения процесса после замены снимка, но до очистки журнала
+	require.NoError(t, os.WriteFile(walPath, journal, 0o644))
+
+	restored, err := NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	assertRestored(t, restored, user.ID)
+
+	// нумерация продолжается после снимка, новые записи не пропускаются при восстановлении
+	require.NoError(t, restored.AddAccrualLog(ctx, model.AccrualLog{Order: "2377225624", Status: "PROCESSING", HTTPCode: 200}))
+	journal, err = os.ReadFile(walPath)
+	require.NoError(t, err)
+	require.NoError(t, restored.Close())
+	require.NoError(t, os.WriteFile(walPath, journal, 0o644))
+
+	restored, err = NewPersistentStorage(dir, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+	assertRestored(t, restored, user.ID)
+	logs, err := restored.GetAccrualLogsByOrder(ctx, "2377225624")
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
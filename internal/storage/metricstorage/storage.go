@@ -0,0 +1,323 @@
+package metricstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+	"github.com/superles/yapgofermart/internal/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	callDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_call_duration_seconds",
+		Help:    "длительность вызовов хранилища",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"method"})
+	callErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_call_errors_total",
+		Help: "количество вызовов хранилища с ошибкой",
+	}, []string{"method"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(callDuration, callErrors)
+}
+
+// redacted значение вместо секретов в логах
+const redacted = "[redacted]"
+
+// InstrumentedStorage хранилище с учётом длительности и ошибок каждого метода
+// и логированием вызовов дольше SlowThreshold с аргументами
+type InstrumentedStorage struct {
+	next          storage.Storage
+	slowThreshold time.Duration
+}
+
+// New обёртка хранилища, slowThreshold 0 отключает логирование медленных вызовов
+func New(next storage.Storage, slowThreshold time.Duration) *InstrumentedStorage {
+	return &InstrumentedStorage{next: next, slowThreshold: slowThreshold}
+}
+
+// observe начало вызова method: span хранилища в ctx и учёт длительности. Возвращённая функция вызывается
+// через defer с указателем на возвращаемую ошибку, медленный вызов логируется логгером запроса из ctx
+func (s *InstrumentedStorage) observe(ctx context.Context, method string, args ...any) (context.Context, func(errp *error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+method, trace.WithAttributes(attribute.String("storage.method", method)))
+	return ctx, func(errp *error) {
+		elapsed := time.Since(start)
+		callDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+
+		err := *errp
+		// отсутствие записи и бизнес ошибки - ожидаемый результат, а не сбой хранилища
+		if err != nil && !isExpected(err) {
+			callErrors.WithLabelValues(method).Inc()
+			tracing.End(span, err)
+		} else {
+			span.End()
+		}
+
+		if s.slowThreshold > 0 && elapsed >= s.slowThreshold {
+			logger.FromContext(ctx).Warnw("медленный вызов хранилища",
+				"method", method,
+				"args", formatArgs(args),
+				"duration", elapsed.String(),
+				"error", errString(err),
+			)
+		}
+	}
+}
+
+func isExpected(err error) bool {
+	return errors.Is(err, errs.ErrNoRows) ||
+		errors.Is(err, errs.ErrExistsSameUser) ||
+		errors.Is(err, errs.ErrExistsAnotherUser) ||
+		errors.Is(err, errs.ErrUserExists) ||
+		errors.Is(err, errs.ErrWithdrawalNotEnoughBalance) ||
+		errors.Is(err, errs.ErrOrderFinal) ||
+		errors.Is(err, errs.ErrWithdrawalExists)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// formatArgs аргументы вызова для лога без секретов
+func formatArgs(args []any) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		parts = append(parts, fmt.Sprintf("%+v", redact(arg)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func redact(arg any) any {
+	switch v := arg.(type) {
+	case model.User:
+		if len(v.PasswordHash) > 0 {
+			v.PasswordHash = redacted
+		}
+		return v
+	default:
+		return arg
+	}
+}
+
+func (s *InstrumentedStorage) GetUserByName(ctx context.Context, name string) (user model.User, err error) {
+	ctx, done := s.observe(ctx, "GetUserByName", name)
+	defer done(&err)
+	return s.next.GetUserByName(ctx, name)
+}
+
+func (s *InstrumentedStorage) GetUserByID(ctx context.Context, id int64) (user model.User, err error) {
+	ctx, done := s.observe(ctx, "GetUserByID", id)
+	defer done(&err)
+	return s.next.GetUserByID(ctx, id)
+}
+
+func (s *InstrumentedStorage) RegisterUser(ctx context.Context, data model.User) (user model.User, err error) {
+	ctx, done := s.observe(ctx, "RegisterUser", data)
+	defer done(&err)
+	return s.next.RegisterUser(ctx, data)
+}
+
+func (s *InstrumentedStorage) GetAllNewAndProcessingOrders(ctx context.Context) (orders []model.Order, err error) {
+	ctx, done := s.observe(ctx, "GetAllNewAndProcessingOrders")
+	defer done(&err)
+	return s.next.GetAllNewAndProcessingOrders(ctx)
+}
+
+func (s *InstrumentedStorage) GetAllOrdersByUser(ctx context.Context, userID int64) (orders []model.Order, err error) {
+	ctx, done := s.observe(ctx, "GetAllOrdersByUser", userID)
+	defer done(&err)
+	return s.next.GetAllOrdersByUser(ctx, userID)
+}
+
+func (s *InstrumentedStorage) GetOrder(ctx context.Context, number string) (order model.Order, err error) {
+	ctx, done := s.observe(ctx, "GetOrder", number)
+	defer done(&err)
+	return s.next.GetOrder(ctx, number)
+}
+
+func (s *InstrumentedStorage) CreateNewOrder(ctx context.Context, number string, userID int64) (err error) {
+	ctx, done := s.observe(ctx, "CreateNewOrder", number, userID)
+	defer done(&err)
+	return s.next.CreateNewOrder(ctx, number, userID)
+}
+
+func (s *InstrumentedStorage) UpdateOrderStatus(ctx context.Context, number string, status string) (err error) {
+	ctx, done := s.observe(ctx, "UpdateOrderStatus", number, status)
+	defer done(&err)
+	return s.next.UpdateOrderStatus(ctx, number, status)
+}
+
+func (s *InstrumentedStorage) IncrementOrderAttempts(ctx context.Context, number string) (err error) {
+	ctx, done := s.observe(ctx, "IncrementOrderAttempts", number)
+	defer done(&err)
+	return s.next.IncrementOrderAttempts(ctx, number)
+}
+
+func (s *InstrumentedStorage) MarkStuckOrders(ctx context.Context, maxAge time.Duration, maxAttempts int) (count int64, err error) {
+	ctx, done := s.observe(ctx, "MarkStuckOrders", maxAge, maxAttempts)
+	defer done(&err)
+	return s.next.MarkStuckOrders(ctx, maxAge, maxAttempts)
+}
+
+func (s *InstrumentedStorage) GetAllStuckOrders(ctx context.Context) (orders []model.Order, err error) {
+	ctx, done := s.observe(ctx, "GetAllStuckOrders")
+	defer done(&err)
+	return s.next.GetAllStuckOrders(ctx)
+}
+
+func (s *InstrumentedStorage) RequeueStuckOrder(ctx context.Context, number string) (err error) {
+	ctx, done := s.observe(ctx, "RequeueStuckOrder", number)
+	defer done(&err)
+	return s.next.RequeueStuckOrder(ctx, number)
+}
+
+func (s *InstrumentedStorage) GetAllWithdrawalsByUserID(ctx context.Context, id int64) (withdrawals []model.Withdrawal, err error) {
+	ctx, done := s.observe(ctx, "GetAllWithdrawalsByUserID", id)
+	defer done(&err)
+	return s.next.GetAllWithdrawalsByUserID(ctx, id)
+}
+
+func (s *InstrumentedStorage) GetWithdrawnSumByUserID(ctx context.Context, userID int64) (sum float64, err error) {
+	ctx, done := s.observe(ctx, "GetWithdrawnSumByUserID", userID)
+	defer done(&err)
+	return s.next.GetWithdrawnSumByUserID(ctx, userID)
+}
+
+func (s *InstrumentedStorage) AddAccrualLog(ctx context.Context, entry model.AccrualLog) (err error) {
+	ctx, done := s.observe(ctx, "AddAccrualLog", entry)
+	defer done(&err)
+	return s.next.AddAccrualLog(ctx, entry)
+}
+
+func (s *InstrumentedStorage) GetAccrualLogsByOrder(ctx context.Context, number string) (logs []model.AccrualLog, err error) {
+	ctx, done := s.observe(ctx, "GetAccrualLogsByOrder", number)
+	defer done(&err)
+	return s.next.GetAccrualLogsByOrder(ctx, number)
+}
+
+func (s *InstrumentedStorage) GetRandomProcessedOrders(ctx context.Context, limit int) (orders []model.Order, err error) {
+	ctx, done := s.observe(ctx, "GetRandomProcessedOrders", limit)
+	defer done(&err)
+	return s.next.GetRandomProcessedOrders(ctx, limit)
+}
+
+func (s *InstrumentedStorage) DeleteOldAccrualLogs(ctx context.Context, maxAge time.Duration) (count int64, err error) {
+	ctx, done := s.observe(ctx, "DeleteOldAccrualLogs", maxAge)
+	defer done(&err)
+	return s.next.DeleteOldAccrualLogs(ctx, maxAge)
+}
+
+func (s *InstrumentedStorage) ExportUsers(ctx context.Context, fn func(user model.User) error) (err error) {
+	ctx, done := s.observe(ctx, "ExportUsers")
+	defer done(&err)
+	return s.next.ExportUsers(ctx, fn)
+}
+
+func (s *InstrumentedStorage) ExportOrders(ctx context.Context, fn func(order model.Order) error) (err error) {
+	ctx, done := s.observe(ctx, "ExportOrders")
+	defer done(&err)
+	return s.next.ExportOrders(ctx, fn)
+}
+
+func (s *InstrumentedStorage) ExportWithdrawals(ctx context.Context, fn func(withdrawal model.Withdrawal) error) (err error) {
+	ctx, done := s.observe(ctx, "ExportWithdrawals")
+	defer done(&err)
+	return s.next.ExportWithdrawals(ctx, fn)
+}
+
+func (s *InstrumentedStorage) ImportUser(ctx context.Context, user model.User) (err error) {
+	ctx, done := s.observe(ctx, "ImportUser", user)
+	defer done(&err)
+	return s.next.ImportUser(ctx, user)
+}
+
+func (s *InstrumentedStorage) ImportOrder(ctx context.Context, order model.Order) (err error) {
+	ctx, done := s.observe(ctx, "ImportOrder", order)
+	defer done(&err)
+	return s.next.ImportOrder(ctx, order)
+}
+
+func (s *InstrumentedStorage) ImportWithdrawal(ctx context.Context, withdrawal model.Withdrawal) (err error) {
+	ctx, done := s.observe(ctx, "ImportWithdrawal", withdrawal)
+	defer done(&err)
+	return s.next.ImportWithdrawal(ctx, withdrawal)
+}
+
+// WithTx учёт транзакции целиком и каждой операции внутри неё с префиксом Tx
+func (s *InstrumentedStorage) WithTx(ctx context.Context, fn func(tx storage.Tx) error) (err error) {
+	ctx, done := s.observe(ctx, "WithTx")
+	defer done(&err)
+	return s.next.WithTx(ctx, func(tx storage.Tx) error {
+		return fn(&instrumentedTx{next: tx, s: s})
+	})
+}
+
+// Close закрытие хранилища, если оно это поддерживает
+func (s *InstrumentedStorage) Close() error {
+	if closer, ok := s.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Ping проверка соединения хранилища, если оно это поддерживает
+func (s *InstrumentedStorage) Ping(ctx context.Context) error {
+	return storage.Ping(ctx, s.next)
+}
+
+// SchemaVersion версия схемы хранилища, если оно это поддерживает
+func (s *InstrumentedStorage) SchemaVersion(ctx context.Context) (int64, int64, error) {
+	return storage.SchemaVersion(ctx, s.next)
+}
+
+type instrumentedTx struct {
+	next storage.Tx
+	s    *InstrumentedStorage
+}
+
+func (t *instrumentedTx) GetUserForUpdate(ctx context.Context, id int64) (user model.User, err error) {
+	ctx, done := t.s.observe(ctx, "Tx.GetUserForUpdate", id)
+	defer done(&err)
+	return t.next.GetUserForUpdate(ctx, id)
+}
+
+func (t *instrumentedTx) GetOrderForUpdate(ctx context.Context, number string) (order model.Order, err error) {
+	ctx, done := t.s.observe(ctx, "Tx.GetOrderForUpdate", number)
+	defer done(&err)
+	return t.next.GetOrderForUpdate(ctx, number)
+}
+
+func (t *instrumentedTx) UpdateUserBalance(ctx context.Context, id int64, balance float64) (err error) {
+	ctx, done := t.s.observe(ctx, "Tx.UpdateUserBalance", id, balance)
+	defer done(&err)
+	return t.next.UpdateUserBalance(ctx, id, balance)
+}
+
+func (t *instrumentedTx) UpdateOrder(ctx context.Context, order model.Order) (err error) {
+	ctx, done := t.s.observe(ctx, "Tx.UpdateOrder", order)
+	defer done(&err)
+	return t.next.UpdateOrder(ctx, order)
+}
+
+func (t *instrumentedTx) AddWithdrawal(ctx context.Context, withdrawal model.Withdrawal) (err error) {
+	ctx, done := t.s.observe(ctx, "Tx.AddWithdrawal", withdrawal)
+	defer done(&err)
+	return t.next.AddWithdrawal(ctx, withdrawal)
+}
@@ -0,0 +1,85 @@
+package metricstorage
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"github.com/superles/yapgofermart/internal/storage/storagetest"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := memstorage.NewStorage()
+		require.NoError(t, err)
+		return New(s, 0)
+	})
+}
+
+// slowStorage хранилище с задержкой каждого вызова RegisterUser
+type slowStorage struct {
+	storage.Storage
+	delay time.Duration
+}
+
+func (s *slowStorage) RegisterUser(ctx context.Context, user model.User) (model.User, error) {
+	time.Sleep(s.delay)
+	return s.Storage.RegisterUser(ctx, user)
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	prev := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	defer func() { logger.Log = prev }()
+
+	ctx := context.Background()
+	inner, err := memstorage.NewStorage()
+	require.NoError(t, err)
+	s := New(&slowStorage{Storage: inner, delay: 20 * time.Millisecond}, 10*time.Millisecond)
+
+	_, err = s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "secret-hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	_, err = s.GetUserByName(ctx, "user")
+	require.NoError(t, err)
+
+	entries := logs.FilterMessage("медленный вызов хранилища").All()
+	require.Len(t, entries, 1, "логируется только медленный вызов")
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "RegisterUser", fields["method"])
+	assert.Contains(t, fields["args"], "Name:user")
+	assert.NotContains(t, fields["args"], "secret-hash", "хеш пароля не попадает в лог")
+
+	var metric dto.Metric
+	require.NoError(t, callDuration.WithLabelValues("RegisterUser").(prometheus.Histogram).Write(&metric))
+	assert.NotZero(t, metric.GetHistogram().GetSampleCount())
+
+	// отсутствие записи не считается ошибкой хранилища
+	before := testutil.ToFloat64(callErrors.WithLabelValues("GetUserByID"))
+	_, err = s.GetUserByID(ctx, 100)
+	assert.Error(t, err)
+	assert.Equal(t, before, testutil.ToFloat64(callErrors.WithLabelValues("GetUserByID")))
+
+	// повторный ответ по заказу в конечном статусе тоже ожидаем
+	user, err := s.GetUserByName(ctx, "user")
+	require.NoError(t, err)
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+	before = testutil.ToFloat64(callErrors.WithLabelValues("UpdateOrderStatus"))
+	assert.ErrorIs(t, s.UpdateOrderStatus(ctx, "12345678903", model.OrderStatusProcessing), errs.ErrOrderFinal)
+	assert.Equal(t, before, testutil.ToFloat64(callErrors.WithLabelValues("UpdateOrderStatus")))
+	before = testutil.ToFloat64(callErrors.WithLabelValues("WithTx"))
+	assert.ErrorIs(t, storage.CreditOrder(ctx, s, "12345678903", 100), errs.ErrOrderFinal)
+	assert.Equal(t, before, testutil.ToFloat64(callErrors.WithLabelValues("WithTx")))
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"github.com/superles/yapgofermart/internal/model"
+	"time"
 )
 
 type OrderStorage interface {
@@ -10,6 +11,11 @@ type OrderStorage interface {
 	GetAllOrdersByUser(ctx context.Context, userID int64) ([]model.Order, error)
 	GetOrder(ctx context.Context, number string) (model.Order, error)
 	CreateNewOrder(ctx context.Context, number string, userID int64) error
+	// UpdateOrderStatus смена статуса заказа в NEW, PROCESSING или STUCK, заказ в PROCESSED или INVALID не меняется
+	// и возвращается errs.ErrOrderFinal, чтобы повторный или запоздавший ответ не вернул заказ в опрос
 	UpdateOrderStatus(ctx context.Context, number string, status string) error
-	SetOrderProcessedAndUserBalance(ctx context.Context, number string, sum float64) error
+	IncrementOrderAttempts(ctx context.Context, number string) error
+	MarkStuckOrders(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error)
+	GetAllStuckOrders(ctx context.Context) ([]model.Order, error)
+	RequeueStuckOrder(ctx context.Context, number string) error
 }
@@ -0,0 +1,65 @@
+package pgstorage
+
+import (
+	"context"
+	"github.com/superles/yapgofermart/internal/model"
+	"time"
+)
+
+// AddAccrualLog запись ответа системы расчёта в журнал и сохранение последнего статуса расчёта в заказе
+func (s *PgStorage) AddAccrualLog(ctx context.Context, entry model.AccrualLog) error {
+	_, err := s.db.Exec(ctx, `with log as (
+			insert into accrual_log (order_number, status, accrual, http_code, error) values ($1, nullif($2::text, ''), $3, $4, nullif($5::text, ''))
+		)
+		update orders set accrual_status=$2::text where number=$1 and $2::text <> ''`,
+		entry.Order, entry.Status, entry.Accrual, entry.HTTPCode, entry.Error)
+	return err
+}
+
+func (s *PgStorage) GetAccrualLogsByOrder(ctx context.Context, number string) ([]model.AccrualLog, error) {
+	var items []model.AccrualLog
+	rows, err := s.db.Query(ctx, `select id, order_number, coalesce(status, ''), accrual, http_code, coalesce(error, ''), created_at from accrual_log where order_number=$1 order by created_at asc, id asc`, number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.AccrualLog
+		err = rows.Scan(&item.ID, &item.Order, &item.Status, &item.Accrual, &item.HTTPCode, &item.Error, &item.CreatedAt)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// DeleteOldAccrualLogs удаление записей журнала ответов системы расчёта старше maxAge
+func (s *PgStorage) DeleteOldAccrualLogs(ctx context.Context, maxAge time.Duration) (int64, error) {
+	tag, err := s.db.Exec(ctx, `delete from accrual_log where created_at < now() - $1 * interval '1 second'`, maxAge.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetRandomProcessedOrders случайная выборка заказов в статусе PROCESSED для сверки с системой расчёта
+func (s *PgStorage) GetRandomProcessedOrders(ctx context.Context, limit int) ([]model.Order, error) {
+	var items []model.Order
+	rows, err := s.db.Query(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders where status=$1 order by random() limit $2`, model.OrderStatusProcessed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.Order
+		err = rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,30 @@
+package pgstorage
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/storagetest"
+	"os"
+	"testing"
+)
+
+// testDsnEnv переменная окружения со строкой подключения к тестовой бд, все данные в ней удаляются
+const testDsnEnv = "TEST_DATABASE_URI"
+
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv(testDsnEnv)
+	if len(dsn) == 0 {
+		t.Skipf("не задана %s", testDsnEnv)
+	}
+
+	s, err := NewStorage(dsn)
+	require.NoError(t, err)
+	defer s.Close()
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		_, err := s.db.Exec(context.Background(), "truncate accrual_log, withdrawals, orders, users restart identity cascade")
+		require.NoError(t, err)
+		return s
+	})
+}
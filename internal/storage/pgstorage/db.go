@@ -2,22 +2,62 @@ package pgstorage
 
 import (
 	"context"
-	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"os"
+	"github.com/superles/yapgofermart/internal/utils/logger"
 )
 
 type PgStorage struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	replicas *replicaSet // replicas реплики для чтения, nil - все запросы в мастер
 }
 
 func NewStorage(dsn string) (*PgStorage, error) {
+	return NewStorageWithReplicas(dsn, ReplicaConfig{})
+}
+
+// NewStorageWithReplicas хранилище с чтением данных пользователей из реплик, миграции применяются к мастеру
+func NewStorageWithReplicas(dsn string, replicaConfig ReplicaConfig) (*PgStorage, error) {
 	ctx := context.Background()
+
+	db, err := Connect(ctx, dsn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrateUp(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &PgStorage{db: db}
+	if len(replicaConfig.DSNs) > 0 {
+		if s.replicas, err = newReplicaSet(ctx, replicaConfig); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *PgStorage) Close() error {
+	if s.replicas != nil {
+		s.replicas.close()
+	}
+	s.db.Close()
+	return nil
+}
+
+// Connect создание пула соединений с проверкой доступности бд
+func Connect(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	dbConfig, dbErr := pgxpool.ParseConfig(dsn)
 
 	if dbErr != nil {
 		return nil, dbErr
 	}
+	dbConfig.ConnConfig.Tracer = Tracer
 
 	db, err := pgxpool.NewWithConfig(ctx, dbConfig)
 
@@ -26,26 +66,24 @@ func NewStorage(dsn string) (*PgStorage, error) {
 	}
 
 	if err := db.Ping(ctx); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	err = checkTables(ctx, db)
-	if err != nil {
-		return nil, err
-	}
-
-	return &PgStorage{db}, nil
+	return db, nil
 }
 
-func checkTables(ctx context.Context, db *pgxpool.Pool) error {
-	sql, err := os.ReadFile("internal/storage/pgstorage/db.sql")
+func migrateUp(ctx context.Context, db *pgxpool.Pool) error {
+	migrator, err := NewMigrator(db)
 	if err != nil {
 		return err
 	}
-	code := string(sql)
-	_, err = db.Exec(ctx, code)
+	applied, err := migrator.Up(ctx)
 	if err != nil {
-		return fmt.Errorf("create tables error: %w", err)
+		return err
+	}
+	for _, m := range applied {
+		logger.FromContext(ctx).Infof("применена миграция %d_%s", m.Version, m.Name)
 	}
 	return nil
 }
@@ -0,0 +1,102 @@
+package pgstorage
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+)
+
+// exportRows вызов scan для каждой строки запроса
+func (s *PgStorage) exportRows(ctx context.Context, sql string, scan func(rows pgx.Rows) error) error {
+	rows, err := s.db.Query(ctx, sql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *PgStorage) ExportUsers(ctx context.Context, fn func(user model.User) error) error {
+	return s.exportRows(ctx, `select id, name, password_hash, coalesce(role, ''), coalesce(balance, 0), withdrawn_total from users order by id`, func(rows pgx.Rows) error {
+		var item model.User
+		if err := rows.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+func (s *PgStorage) ExportOrders(ctx context.Context, fn func(order model.Order) error) error {
+	return s.exportRows(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders order by uploaded_at, number`, func(rows pgx.Rows) error {
+		var item model.Order
+		if err := rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+func (s *PgStorage) ExportWithdrawals(ctx context.Context, fn func(withdrawal model.Withdrawal) error) error {
+	return s.exportRows(ctx, `select order_number, user_id, coalesce(sum, 0), processed_at from withdrawals order by id`, func(rows pgx.Rows) error {
+		var item model.Withdrawal
+		if err := rows.Scan(&item.Order, &item.UserID, &item.Sum, &item.ProcessedAt); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+func (s *PgStorage) ImportUser(ctx context.Context, user model.User) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `insert into users (id, name, password_hash, role, balance, withdrawn_total) overriding system value values ($1, $2, $3, $4, $5, $6)`,
+			user.ID, user.Name, user.PasswordHash, user.Role, user.Balance, user.Withdrawn)
+		if isUniqueViolation(err) {
+			return errs.ErrUserExists
+		}
+		if err != nil {
+			return err
+		}
+		// последовательность сдвигается за импортированный id, чтобы новые пользователи его не повторили
+		_, err = tx.Exec(ctx, `select setval(pg_get_serial_sequence('public.users', 'id'), (select max(id) from users))`)
+		return err
+	})
+}
+
+func (s *PgStorage) ImportOrder(ctx context.Context, order model.Order) error {
+	_, err := s.db.Exec(ctx, `insert into orders (number, status, accrual, uploaded_at, user_id, accrual_attempts) values ($1, $2, $3, $4, $5, $6)`,
+		order.Number, order.Status, order.Accrual, order.UploadedAt, order.UserID, order.Attempts)
+	if isUniqueViolation(err) {
+		existing, getErr := s.GetOrder(ctx, order.Number)
+		if getErr == nil && existing.UserID != order.UserID {
+			return errs.ErrExistsAnotherUser
+		}
+		return errs.ErrExistsSameUser
+	}
+	return err
+}
+
+func (s *PgStorage) ImportWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	tag, err := s.db.Exec(ctx, `insert into withdrawals (order_number, user_id, sum, processed_at) select $1, $2, $3, $4
+		where not exists (select 1 from withdrawals where order_number=$1 and user_id=$2 and processed_at=$4)`,
+		withdrawal.Order, withdrawal.UserID, withdrawal.Sum, withdrawal.ProcessedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.ErrWithdrawalExists
+	}
+	return nil
+}
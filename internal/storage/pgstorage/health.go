@@ -0,0 +1,23 @@
+package pgstorage
+
+import (
+	"context"
+)
+
+// Ping проверка соединения с мастером
+func (s *PgStorage) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// SchemaVersion применённая в мастере и последняя встроенная версии схемы
+func (s *PgStorage) SchemaVersion(ctx context.Context) (int64, int64, error) {
+	migrator, err := NewMigrator(s.db)
+	if err != nil {
+		return 0, 0, err
+	}
+	current, err := migrator.Version(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return current, migrator.LatestVersion(), nil
+}
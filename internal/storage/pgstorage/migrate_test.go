@@ -0,0 +1,46 @@
+package pgstorage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_loadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	require.NoError(t, err, "ошибка чтения встроенных миграций")
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "версии миграций должны идти подряд")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "#1 missing down",
+			fsys: fstest.MapFS{"migrations/0001_init.up.sql": {Data: []byte("select 1")}},
+		},
+		{
+			name: "#2 wrong file name",
+			fsys: fstest.MapFS{"migrations/init.sql": {Data: []byte("select 1")}},
+		},
+		{
+			name: "#3 different names",
+			fsys: fstest.MapFS{
+				"migrations/0001_init.up.sql":   {Data: []byte("select 1")},
+				"migrations/0001_test.down.sql": {Data: []byte("select 1")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.fsys)
+			assert.Error(t, err)
+		})
+	}
+}
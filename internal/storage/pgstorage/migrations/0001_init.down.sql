@@ -0,0 +1,5 @@
+drop table if exists public.withdrawals;
+
+drop table if exists public.orders;
+
+drop table if exists public.users;
@@ -31,4 +31,4 @@ create table if not exists public.withdrawals
     user_id      integer                                not null,
     sum          double precision,
     processed_at timestamp with time zone default now() not null
-);
\ No newline at end of file
+);
@@ -0,0 +1,5 @@
+alter table public.orders
+    drop column if exists queued_at;
+
+alter table public.orders
+    drop column if exists accrual_attempts;
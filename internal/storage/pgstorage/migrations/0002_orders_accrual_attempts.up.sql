@@ -0,0 +1,5 @@
+alter table public.orders
+    add column if not exists accrual_attempts integer default 0 not null;
+
+alter table public.orders
+    add column if not exists queued_at timestamp with time zone;
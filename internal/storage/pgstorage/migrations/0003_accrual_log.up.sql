@@ -0,0 +1,15 @@
+create table if not exists public.accrual_log
+(
+    id           integer generated always as identity
+        constraint accrual_log_pk
+            primary key,
+    order_number varchar(255)                           not null,
+    status       varchar(50),
+    accrual      double precision,
+    http_code    integer                                not null,
+    error        text,
+    created_at   timestamp with time zone default now() not null
+);
+
+create index if not exists accrual_log_order_number_idx
+    on public.accrual_log (order_number);
@@ -0,0 +1 @@
+drop index if exists public.users_name_uindex;
@@ -0,0 +1,22 @@
+-- Уникальный индекс по имени пользователя.
+-- Дубликаты не удаляются автоматически: на них могут ссылаться заказы и списания, поэтому миграция
+-- прерывается с перечнем повторяющихся имён. Перед повторным запуском дубликаты нужно объединить
+-- или переименовать вручную, найти их можно запросом:
+--   select name, count(*) from public.users group by name having count(*) > 1;
+do
+$$
+    declare
+        duplicates text;
+    begin
+        select string_agg(quote_literal(name), ', ' order by name)
+        into duplicates
+        from (select name from public.users group by name having count(*) > 1) d;
+        if duplicates is not null then
+            raise exception 'в таблице users есть повторяющиеся имена: %', duplicates
+                using hint = 'объедините или переименуйте пользователей с одинаковым именем и повторите миграцию';
+        end if;
+    end
+$$;
+
+create unique index if not exists users_name_uindex
+    on public.users (name);
@@ -0,0 +1,2 @@
+alter table public.users
+    drop column if exists withdrawn_total;
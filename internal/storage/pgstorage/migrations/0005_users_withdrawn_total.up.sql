@@ -0,0 +1,5 @@
+alter table public.users
+    add column if not exists withdrawn_total double precision default 0 not null;
+
+update public.users
+set withdrawn_total = coalesce((select sum(w.sum) from public.withdrawals w where w.user_id = users.id), 0);
@@ -0,0 +1 @@
+drop index if exists public.accrual_log_created_at_idx;
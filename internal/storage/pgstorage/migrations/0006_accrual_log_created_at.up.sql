@@ -0,0 +1,2 @@
+create index if not exists accrual_log_created_at_idx
+    on public.accrual_log (created_at);
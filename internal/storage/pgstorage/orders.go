@@ -9,19 +9,20 @@ import (
 	errs "github.com/superles/yapgofermart/internal/errors"
 	"github.com/superles/yapgofermart/internal/model"
 	"github.com/superles/yapgofermart/internal/utils/logger"
+	"time"
 )
 
 func (s *PgStorage) GetOrder(ctx context.Context, number string) (model.Order, error) {
 
 	item := model.Order{}
 
-	row := s.db.QueryRow(ctx, `select number, status, accrual, uploaded_at, user_id from orders where number=$1`, number)
+	row := s.db.QueryRow(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders where number=$1`, number)
 
 	if row == nil {
 		return item, errors.New("объект row пустой")
 	}
 
-	if err := row.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID); err != nil {
+	if err := row.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return item, errs.ErrNoRows
 		}
@@ -33,7 +34,7 @@ func (s *PgStorage) GetOrder(ctx context.Context, number string) (model.Order, e
 
 func (s *PgStorage) GetAllOrdersByUser(ctx context.Context, userID int64) ([]model.Order, error) {
 	var items []model.Order
-	rows, err := s.db.Query(ctx, `select number, status, accrual, uploaded_at, user_id from orders where user_id=$1 order by uploaded_at desc`, userID)
+	rows, err := s.reader(userID).Query(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders where user_id=$1 order by uploaded_at desc`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +43,7 @@ func (s *PgStorage) GetAllOrdersByUser(ctx context.Context, userID int64) ([]mod
 	}
 	for rows.Next() {
 		var item model.Order
-		err = rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID)
+		err = rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts)
 		if err != nil {
 			return items, err
 		}
@@ -55,7 +56,7 @@ func (s *PgStorage) GetAllOrdersByUser(ctx context.Context, userID int64) ([]mod
 // GetAllNewAndProcessingOrders получение всех заказов со статусами NEW и PROCESSING для запроса/повторного запроса в системе лояльности(accrual)
 func (s *PgStorage) GetAllNewAndProcessingOrders(ctx context.Context) ([]model.Order, error) {
 	var items []model.Order
-	rows, err := s.db.Query(ctx, `select number, status, accrual, uploaded_at, user_id from orders where status=$1 or status=$2 order by uploaded_at asc`, model.OrderStatusNew, model.OrderStatusProcessing)
+	rows, err := s.db.Query(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders where status=$1 or status=$2 order by uploaded_at asc`, model.OrderStatusNew, model.OrderStatusProcessing)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +65,7 @@ func (s *PgStorage) GetAllNewAndProcessingOrders(ctx context.Context) ([]model.O
 	}
 	for rows.Next() {
 		var item model.Order
-		err = rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID)
+		err = rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts)
 		if err != nil {
 			return items, err
 		}
@@ -85,7 +86,7 @@ func (s *PgStorage) CreateNewOrder(ctx context.Context, number string, userID in
 	defer func(tx pgx.Tx, ctx context.Context) {
 		err := tx.Rollback(ctx)
 		if err != nil {
-			logger.Log.Error(fmt.Sprintf("rollback error: %s", err))
+			logger.FromContext(ctx).Error(fmt.Sprintf("rollback error: %s", err))
 		}
 	}(tx, ctx)
 
@@ -109,55 +110,81 @@ func (s *PgStorage) CreateNewOrder(ctx context.Context, number string, userID in
 	if _, err := tx.Exec(ctx, "insert into orders (number, status, user_id) VALUES ($1, $2, $3)", number, model.OrderStatusNew, userID); err != nil {
 		return err
 	}
+	s.markWrite(userID)
 
 	return tx.Commit(ctx)
 }
 
 func (s *PgStorage) UpdateOrderStatus(ctx context.Context, number string, status string) error {
-	_, err := s.db.Exec(ctx, "update orders set status=$1 where number=$2", number, status)
-	return err
+	tag, err := s.db.Exec(ctx, "update orders set status=$1 where number=$2 and status in ($3, $4, $5)",
+		status, number, model.OrderStatusNew, model.OrderStatusProcessing, model.OrderStatusStuck)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	var exists bool
+	if err := s.db.QueryRow(ctx, "select exists(select 1 from orders where number=$1)", number).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return errs.ErrOrderFinal
+	}
+	return errs.ErrNoRows
 }
 
-func (s *PgStorage) SetOrderProcessedAndUserBalance(ctx context.Context, number string, sum float64) error {
+// IncrementOrderAttempts учёт очередного запроса заказа в систему расчёта
+func (s *PgStorage) IncrementOrderAttempts(ctx context.Context, number string) error {
+	tag, err := s.db.Exec(ctx, "update orders set accrual_attempts=accrual_attempts + 1, accrual_check_at=now() where number=$1", number)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.ErrNoRows
+	}
+	return nil
+}
 
-	if sum < 0 {
-		return errors.New("невозможно начислить отрицательную сумму в качестве бонусов")
+// MarkStuckOrders перевод в статус STUCK заказов NEW и PROCESSING, ожидающих дольше maxAge или опрошенных не менее maxAttempts раз, нулевое значение отключает критерий
+func (s *PgStorage) MarkStuckOrders(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error) {
+	tag, err := s.db.Exec(ctx, `update orders set status=$1 where (status=$2 or status=$3)
+		and (($4::double precision > 0 and coalesce(queued_at, uploaded_at) < now() - $4 * interval '1 second')
+		or ($5::integer > 0 and accrual_attempts >= $5))`,
+		model.OrderStatusStuck, model.OrderStatusNew, model.OrderStatusProcessing, maxAge.Seconds(), maxAttempts)
+	if err != nil {
+		return 0, err
 	}
+	return tag.RowsAffected(), nil
+}
 
-	tx, err := s.db.Begin(ctx)
+func (s *PgStorage) GetAllStuckOrders(ctx context.Context) ([]model.Order, error) {
+	var items []model.Order
+	rows, err := s.db.Query(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders where status=$1 order by uploaded_at asc`, model.OrderStatusStuck)
 	if err != nil {
-		return fmt.Errorf("UpdateOrderStatusAndAccrual. не удалось открыть транзакцию: %w", err)
+		return nil, err
 	}
-	defer func(tx pgx.Tx, ctx context.Context) {
-		err := tx.Rollback(ctx)
+	defer rows.Close()
+	for rows.Next() {
+		var item model.Order
+		err = rows.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts)
 		if err != nil {
-			logger.Log.Error(fmt.Sprintf("rollback error: %s", err))
-		}
-	}(tx, ctx)
-
-	// выбираем заказ для обновления, select for update skip locked - для невозможности параллельной обработки ни горутиной ни другим инстансом
-	row := tx.QueryRow(ctx, "SELECT number, status, accrual, uploaded_at, user_id FROM orders WHERE number = $1 FOR UPDATE SKIP LOCKED", number)
-
-	item := model.Order{}
-
-	if err := row.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return errs.ErrNoRows
+			return items, err
 		}
-		return err
+		items = append(items, item)
 	}
 
-	if item.Status == model.OrderStatusProcessed || item.Status == model.OrderStatusInvalid {
-		return errs.ErrNoRows
-	}
+	return items, rows.Err()
+}
 
-	if _, err := tx.Exec(ctx, "update orders set status=$1, accrual=$2 where number=$3", model.OrderStatusProcessed, sum, number); err != nil {
+// RequeueStuckOrder возврат заказа из статуса STUCK в очередь опроса со сбросом попыток
+func (s *PgStorage) RequeueStuckOrder(ctx context.Context, number string) error {
+	tag, err := s.db.Exec(ctx, "update orders set status=$1, accrual_attempts=0, queued_at=now() where number=$2 and status=$3", model.OrderStatusNew, number, model.OrderStatusStuck)
+	if err != nil {
 		return err
 	}
-
-	if _, err := tx.Exec(ctx, "update users set balance=coalesce(balance, 0) + $1 where id=$2", sum, item.UserID); err != nil {
-		return err
+	if tag.RowsAffected() == 0 {
+		return errs.ErrNoRows
 	}
-
-	return tx.Commit(ctx)
+	return nil
 }
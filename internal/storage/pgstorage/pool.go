@@ -0,0 +1,54 @@
+package pgstorage
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector статистика пула соединений мастера из pgxpool.Stat() на момент сбора
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	constructing    *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquire    *prometheus.Desc
+	canceledAcquire *prometheus.Desc
+}
+
+// Collector метрики пула соединений мастера для регистрации в реестре Prometheus
+func (s *PgStorage) Collector() prometheus.Collector {
+	return &poolCollector{
+		pool:            s.db,
+		acquired:        prometheus.NewDesc("pg_pool_acquired_conns", "количество занятых соединений пула", nil, nil),
+		idle:            prometheus.NewDesc("pg_pool_idle_conns", "количество свободных соединений пула", nil, nil),
+		constructing:    prometheus.NewDesc("pg_pool_constructing_conns", "количество устанавливаемых соединений пула", nil, nil),
+		total:           prometheus.NewDesc("pg_pool_total_conns", "количество соединений пула", nil, nil),
+		max:             prometheus.NewDesc("pg_pool_max_conns", "максимальное количество соединений пула", nil, nil),
+		acquireCount:    prometheus.NewDesc("pg_pool_acquire_total", "количество получений соединения из пула", nil, nil),
+		acquireDuration: prometheus.NewDesc("pg_pool_acquire_duration_seconds_total", "суммарное время ожидания соединения из пула", nil, nil),
+		emptyAcquire:    prometheus.NewDesc("pg_pool_empty_acquire_total", "количество получений соединения с ожиданием из-за пустого пула", nil, nil),
+		canceledAcquire: prometheus.NewDesc("pg_pool_canceled_acquire_total", "количество отменённых получений соединения", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructing, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquire, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
@@ -0,0 +1,158 @@
+package pgstorage
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaCheckInterval интервал проверки отставания реплик
+const replicaCheckInterval = time.Second
+
+// replicaLagQuery отставание реплики в секундах. Пока все полученные изменения применены,
+// отставание нулевое, иначе на простаивающем мастере время последней транзакции растёт без реального отставания
+const replicaLagQuery = `select case when pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0
+	else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0) end::double precision`
+
+// querier запросы на чтение, общие для пула мастера и реплик
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ReplicaConfig настройки реплик для чтения
+type ReplicaConfig struct {
+	DSNs   []string      // DSNs строки подключения к репликам
+	MaxLag time.Duration // MaxLag максимальное отставание, при большем реплика не используется
+}
+
+type replica struct {
+	dsn     string
+	db      querier
+	close   func()
+	healthy atomic.Bool
+}
+
+// replicaSet реплики для чтения с выбором по кругу среди не отстающих
+type replicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+
+	// writes время последней записи по пользователю: его чтения в течение maxLag идут в мастер,
+	// чтобы пользователь видел свои изменения
+	writes sync.Map
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplicaSet(ctx context.Context, cfg ReplicaConfig) (*replicaSet, error) {
+	set := &replicaSet{maxLag: cfg.MaxLag, done: make(chan struct{})}
+	for _, dsn := range cfg.DSNs {
+		db, err := Connect(ctx, dsn)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		set.replicas = append(set.replicas, &replica{dsn: dsn, db: db, close: db.Close})
+	}
+	set.check(ctx)
+
+	set.wg.Add(1)
+	go set.checkLoop()
+	return set, nil
+}
+
+func (r *replicaSet) checkLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), replicaCheckInterval)
+			r.check(ctx)
+			cancel()
+		}
+	}
+}
+
+// check обновление состояния реплик по их отставанию и очистка устаревших записей пользователей
+func (r *replicaSet) check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		var lag float64
+		err := rep.db.QueryRow(ctx, replicaLagQuery).Scan(&lag)
+		healthy := err == nil && time.Duration(lag*float64(time.Second)) <= r.maxLag
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.Log.Infof("реплика %s доступна для чтения", rep.dsn)
+			} else if err != nil {
+				logger.Log.Warnf("реплика %s исключена из чтения: %s", rep.dsn, err.Error())
+			} else {
+				logger.Log.Warnf("реплика %s исключена из чтения: отставание %.1fs", rep.dsn, lag)
+			}
+		}
+	}
+
+	r.writes.Range(func(key, value any) bool {
+		if time.Since(value.(time.Time)) > r.maxLag {
+			r.writes.Delete(key)
+		}
+		return true
+	})
+}
+
+// pick следующая не отстающая реплика, nil если таких нет
+func (r *replicaSet) pick() querier {
+	n := len(r.replicas)
+	start := r.next.Add(1)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+uint64(i))%uint64(n)]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return nil
+}
+
+func (r *replicaSet) markWrite(userID int64) {
+	r.writes.Store(userID, time.Now())
+}
+
+func (r *replicaSet) recentlyWritten(userID int64) bool {
+	at, ok := r.writes.Load(userID)
+	return ok && time.Since(at.(time.Time)) <= r.maxLag
+}
+
+func (r *replicaSet) close() {
+	close(r.done)
+	r.wg.Wait()
+	for _, rep := range r.replicas {
+		rep.close()
+	}
+}
+
+// reader соединение для чтения данных пользователя: реплика, если она не отстаёт
+// и пользователь недавно ничего не менял, иначе мастер
+func (s *PgStorage) reader(userID int64) querier {
+	if s.replicas == nil || s.replicas.recentlyWritten(userID) {
+		return s.db
+	}
+	if db := s.replicas.pick(); db != nil {
+		return db
+	}
+	return s.db
+}
+
+// markWrite учёт записи данных пользователя для чтения своих изменений из мастера
+func (s *PgStorage) markWrite(userID int64) {
+	if s.replicas != nil {
+		s.replicas.markWrite(userID)
+	}
+}
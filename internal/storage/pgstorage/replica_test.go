@@ -0,0 +1,67 @@
+package pgstorage
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// fakeReplica реплика с заданным отставанием в секундах
+type fakeReplica struct {
+	lag float64
+	err error
+}
+
+func (f *fakeReplica) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeReplica) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return f
+}
+
+func (f *fakeReplica) Scan(dest ...any) error {
+	if f.err != nil {
+		return f.err
+	}
+	*dest[0].(*float64) = f.lag
+	return nil
+}
+
+func TestReplicaRouting(t *testing.T) {
+	fresh := &fakeReplica{lag: 1}
+	lagging := &fakeReplica{lag: 10}
+	broken := &fakeReplica{err: errors.New("connection refused")}
+
+	set := &replicaSet{maxLag: 5 * time.Second, done: make(chan struct{})}
+	for _, db := range []*fakeReplica{fresh, lagging, broken} {
+		set.replicas = append(set.replicas, &replica{dsn: "replica", db: db, close: func() {}})
+	}
+	set.check(context.Background())
+	s := &PgStorage{replicas: set}
+
+	for i := 0; i < 6; i++ {
+		assert.True(t, s.reader(1) == fresh, "чтение только из не отстающей реплики")
+	}
+
+	s.markWrite(1)
+	assert.True(t, s.reader(1) == querier(s.db), "после записи пользователь читает из мастера")
+	assert.True(t, s.reader(2) == fresh, "запись одного пользователя не влияет на чтение другого")
+
+	set.writes.Store(int64(1), time.Now().Add(-time.Minute))
+	set.check(context.Background())
+	assert.True(t, s.reader(1) == fresh, "после окна отставания чтение снова из реплики")
+
+	fresh.lag = 6
+	set.check(context.Background())
+	assert.True(t, s.reader(1) == querier(s.db), "все реплики отстают")
+
+	lagging.lag = 0
+	set.check(context.Background())
+	assert.True(t, s.reader(1) == lagging, "реплика догнала мастер")
+
+	assert.True(t, (&PgStorage{}).reader(1) == querier((&PgStorage{}).db), "без реплик чтение из мастера")
+}
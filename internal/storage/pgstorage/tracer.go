@@ -0,0 +1,89 @@
+package pgstorage
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"github.com/superles/yapgofermart/internal/utils/metrics"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxStatementLabel длина текста запроса в имени метрики
+const maxStatementLabel = 120
+
+var (
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pg_query_duration_seconds",
+		Help:    "длительность sql запросов Postgres",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"statement"})
+	queryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pg_query_errors_total",
+		Help: "количество sql запросов Postgres с ошибкой",
+	}, []string{"statement"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(queryDuration, queryErrors)
+}
+
+// QueryTracer учёт длительности sql запросов всех пулов пакета, подключается в Connect
+type QueryTracer struct {
+	slowThreshold atomic.Int64
+}
+
+// Tracer трассировщик запросов, общий для мастера и реплик
+var Tracer = &QueryTracer{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql   string
+	args  int
+	start time.Time
+}
+
+// SetSlowThreshold порог длительности запроса для записи в лог, 0 - не логировать
+func (t *QueryTracer) SetSlowThreshold(threshold time.Duration) {
+	t.slowThreshold.Store(int64(threshold))
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: data.SQL, args: len(data.Args), start: time.Now()})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.start)
+	label := statementLabel(start.sql)
+	queryDuration.WithLabelValues(label).Observe(elapsed.Seconds())
+	if data.Err != nil {
+		queryErrors.WithLabelValues(label).Inc()
+	}
+
+	if threshold := time.Duration(t.slowThreshold.Load()); threshold > 0 && elapsed >= threshold {
+		// значения параметров не логируются: среди них могут быть хеши паролей
+		logger.FromContext(ctx).Warnw("медленный sql запрос",
+			"sql", start.sql,
+			"args", start.args,
+			"rows", data.CommandTag.RowsAffected(),
+			"duration", elapsed.String(),
+		)
+	}
+}
+
+// statementLabel текст запроса в одну строку с ограничением длины, запросы пакета статичны,
+// поэтому количество меток ограничено количеством запросов в коде
+func statementLabel(sql string) string {
+	label := strings.Join(strings.Fields(sql), " ")
+	if len(label) > maxStatementLabel {
+		label = label[:maxStatementLabel]
+	}
+	return label
+}
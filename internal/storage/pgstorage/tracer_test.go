@@ -0,0 +1,35 @@
+package pgstorage
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestStatementLabel(t *testing.T) {
+	assert.Equal(t, "select id from users where id=$1", statementLabel("select id\n\t\tfrom users   where id=$1"))
+	assert.Len(t, statementLabel("select "+strings.Repeat("x, ", 100)+"y from t"), maxStatementLabel)
+}
+
+func TestQueryTracer(t *testing.T) {
+	tracer := &QueryTracer{}
+	sql := "select 1 /* tracer test */"
+	label := statementLabel(sql)
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: sql})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: sql})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("ошибка")})
+
+	var metric dto.Metric
+	require.NoError(t, queryDuration.WithLabelValues(label).(prometheus.Histogram).Write(&metric))
+	assert.Equal(t, uint64(2), metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(1), testutil.ToFloat64(queryErrors.WithLabelValues(label)))
+}
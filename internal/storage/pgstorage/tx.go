@@ -0,0 +1,92 @@
+package pgstorage
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"time"
+)
+
+// pgTx реализация storage.Tx поверх транзакции pgx, блокировки строк через select for update
+type pgTx struct {
+	tx pgx.Tx
+	s  *PgStorage
+}
+
+func (s *PgStorage) WithTx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		return fn(&pgTx{tx: tx, s: s})
+	})
+}
+
+func (t *pgTx) GetUserForUpdate(ctx context.Context, id int64) (model.User, error) {
+	item := model.User{}
+
+	row := t.tx.QueryRow(ctx, `select id, name, password_hash, role, coalesce(balance, 0), withdrawn_total from users where id=$1 for update`, id)
+
+	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return item, errs.ErrNoRows
+		}
+		return item, err
+	}
+
+	return item, nil
+}
+
+func (t *pgTx) GetOrderForUpdate(ctx context.Context, number string) (model.Order, error) {
+	item := model.Order{}
+
+	row := t.tx.QueryRow(ctx, `select number, status, accrual, uploaded_at, user_id, accrual_attempts from orders where number=$1 for update`, number)
+
+	if err := row.Scan(&item.Number, &item.Status, &item.Accrual, &item.UploadedAt, &item.UserID, &item.Attempts); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return item, errs.ErrNoRows
+		}
+		return item, err
+	}
+
+	return item, nil
+}
+
+func (t *pgTx) UpdateUserBalance(ctx context.Context, id int64, balance float64) error {
+	tag, err := t.tx.Exec(ctx, "update users set balance=$1 where id=$2", balance, id)
+	if err != nil {
+		return err
+	}
+	t.s.markWrite(id)
+	if tag.RowsAffected() == 0 {
+		return errs.ErrNoRows
+	}
+	return nil
+}
+
+func (t *pgTx) UpdateOrder(ctx context.Context, order model.Order) error {
+	tag, err := t.tx.Exec(ctx, "update orders set status=$1, accrual=$2, accrual_attempts=$3 where number=$4", order.Status, order.Accrual, order.Attempts, order.Number)
+	if err != nil {
+		return err
+	}
+	t.s.markWrite(order.UserID)
+	if tag.RowsAffected() == 0 {
+		return errs.ErrNoRows
+	}
+	return nil
+}
+
+func (t *pgTx) AddWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	processedAt := withdrawal.ProcessedAt
+	if processedAt.IsZero() {
+		processedAt = time.Now()
+	}
+	if _, err := t.tx.Exec(ctx, "insert into withdrawals (order_number, user_id, sum, processed_at) values ($1, $2, $3, $4)", withdrawal.Order, withdrawal.UserID, withdrawal.Sum, processedAt); err != nil {
+		return err
+	}
+	if _, err := t.tx.Exec(ctx, "update users set withdrawn_total=withdrawn_total + $1 where id=$2", withdrawal.Sum, withdrawal.UserID); err != nil {
+		return err
+	}
+	t.s.markWrite(withdrawal.UserID)
+	return nil
+}
@@ -9,13 +9,16 @@ import (
 	"github.com/superles/yapgofermart/internal/model"
 )
 
+// uniqueViolation код ошибки Postgres нарушения уникальности
+const uniqueViolation = "23505"
+
 func (s *PgStorage) GetUserByID(ctx context.Context, id int64) (model.User, error) {
 
 	item := model.User{}
 
-	row := s.db.QueryRow(ctx, `SELECT id, name, password_hash, role, coalesce(balance, 0) from users where id=$1`, id)
+	row := s.reader(id).QueryRow(ctx, `SELECT id, name, password_hash, role, coalesce(balance, 0), withdrawn_total from users where id=$1`, id)
 
-	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance); err != nil {
+	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return item, errs.ErrNoRows
 		}
@@ -29,9 +32,9 @@ func (s *PgStorage) GetUserByName(ctx context.Context, name string) (model.User,
 
 	item := model.User{}
 
-	row := s.db.QueryRow(ctx, `SELECT id, name, password_hash, role, coalesce(balance, 0) from users where name=$1`, name)
+	row := s.db.QueryRow(ctx, `SELECT id, name, password_hash, role, coalesce(balance, 0), withdrawn_total from users where name=$1`, name)
 
-	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance); err != nil {
+	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return item, errs.ErrNoRows
 		}
@@ -44,6 +47,9 @@ func (s *PgStorage) GetUserByName(ctx context.Context, name string) (model.User,
 func (s *PgStorage) RegisterUser(ctx context.Context, data model.User) (model.User, error) {
 	_, err := s.db.Exec(ctx, "insert into users (name, password_hash, role) VALUES ($1, $2, $3)", data.Name, data.PasswordHash, data.Role)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return data, errs.ErrUserExists
+		}
 		return data, err
 	}
 	return s.GetUserByName(ctx, data.Name)
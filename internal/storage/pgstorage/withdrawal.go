@@ -3,17 +3,15 @@ package pgstorage
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/jackc/pgx/v5"
 	errs "github.com/superles/yapgofermart/internal/errors"
 	"github.com/superles/yapgofermart/internal/model"
-	"github.com/superles/yapgofermart/internal/utils/logger"
 )
 
 func (s *PgStorage) GetAllWithdrawalsByUserID(ctx context.Context, id int64) ([]model.Withdrawal, error) {
 
 	var items []model.Withdrawal
-	rows, err := s.db.Query(ctx, `select order_number, user_id, sum, processed_at from withdrawals where user_id = $1 order by processed_at desc`, id)
+	rows, err := s.reader(id).Query(ctx, `select order_number, user_id, sum, processed_at from withdrawals where user_id = $1 order by processed_at desc`, id)
 	if err != nil {
 		return items, err
 	}
@@ -32,60 +30,10 @@ func (s *PgStorage) GetAllWithdrawalsByUserID(ctx context.Context, id int64) ([]
 	return items, nil
 }
 
-// CreateWithdrawal создание записи в withdrawal таблице при условии, что пользователю достаточно баланса + обновление баланса у пользователя
-func (s *PgStorage) CreateWithdrawal(ctx context.Context, number string, withdraw float64, userID int64) error {
-
-	if withdraw <= 0 {
-		// нет ошибки, но поведение подозрительное
-		logger.Log.Warn("передана нулевая или отрицательная сумма списания")
-		return nil
-	}
-
-	tx, err := s.db.Begin(ctx)
-
-	if err != nil {
-		return fmt.Errorf("не удалось открыть транзакцию: %w", err)
-	}
-
-	defer func(tx pgx.Tx, ctx context.Context) {
-		err := tx.Rollback(ctx)
-		if err != nil {
-			logger.Log.Error(fmt.Sprintf("rollback error: %s", err))
-		}
-	}(tx, ctx)
-
-	// Выбираем заказы с определенным статусом для обновления
-	row := tx.QueryRow(ctx, "select id, name, balance from users WHERE id = $1 FOR UPDATE", userID)
-
-	item := model.User{}
-
-	if err := row.Scan(&item.ID, &item.Name, &item.Balance); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			// при skip будет отдана ошибка pgx.ErrNoRows
-			return errs.ErrNoRows
-		}
-		return err
-	}
-
-	if (item.Balance - withdraw) < 0 {
-		// недостаточно средств на балансе
-		return errs.ErrWithdrawalNotEnoughBalance
-	}
-
-	if _, err := tx.Exec(ctx, "update users set balance=coalesce(balance, 0) - $1 where id=$2", withdraw, userID); err != nil {
-		return err
-	}
-
-	if _, err := tx.Exec(ctx, "insert into withdrawals (order_number, user_id, sum) VALUES ($1, $2, $3)", number, userID, withdraw); err != nil {
-		return err
-	}
-
-	return tx.Commit(ctx)
-}
-
 func (s *PgStorage) GetWithdrawnSumByUserID(ctx context.Context, userID int64) (float64, error) {
 	var returnSum float64
-	row := s.db.QueryRow(ctx, "select coalesce(sum(sum),0) from withdrawals where user_id = $1", userID)
+	// сумма поддерживается в users.withdrawn_total при каждом списании
+	row := s.reader(userID).QueryRow(ctx, "select coalesce((select withdrawn_total from users where id = $1), 0)", userID)
 	if row == nil {
 		return 0, errs.ErrNoRows
 	}
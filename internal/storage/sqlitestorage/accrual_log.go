@@ -0,0 +1,56 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/superles/yapgofermart/internal/model"
+	"time"
+)
+
+// AddAccrualLog запись ответа системы расчёта в журнал и сохранение последнего статуса расчёта в заказе
+func (s *SqliteStorage) AddAccrualLog(ctx context.Context, entry model.AccrualLog) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into accrual_log (order_number, status, accrual, http_code, error, created_at) values (?, nullif(?, ''), ?, ?, nullif(?, ''), ?)",
+			entry.Order, entry.Status, entry.Accrual, entry.HTTPCode, entry.Error, toUnix(time.Now()))
+		if err != nil || len(entry.Status) == 0 {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "update orders set accrual_status=? where number=?", entry.Status, entry.Order)
+		return err
+	})
+}
+
+func (s *SqliteStorage) GetAccrualLogsByOrder(ctx context.Context, number string) ([]model.AccrualLog, error) {
+	var items []model.AccrualLog
+	rows, err := s.db.QueryContext(ctx, `select id, order_number, coalesce(status, ''), accrual, http_code, coalesce(error, ''), created_at from accrual_log where order_number=? order by created_at asc, id asc`, number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.AccrualLog
+		var createdAt int64
+		err = rows.Scan(&item.ID, &item.Order, &item.Status, &item.Accrual, &item.HTTPCode, &item.Error, &createdAt)
+		if err != nil {
+			return items, err
+		}
+		item.CreatedAt = fromUnix(createdAt)
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// DeleteOldAccrualLogs удаление записей журнала ответов системы расчёта старше maxAge
+func (s *SqliteStorage) DeleteOldAccrualLogs(ctx context.Context, maxAge time.Duration) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "delete from accrual_log where created_at < ?", toUnix(time.Now().Add(-maxAge)))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetRandomProcessedOrders случайная выборка заказов в статусе PROCESSED для сверки с системой расчёта
+func (s *SqliteStorage) GetRandomProcessedOrders(ctx context.Context, limit int) ([]model.Order, error) {
+	return s.queryOrders(ctx, `select `+orderColumns+` from orders where status=? order by random() limit ?`, model.OrderStatusProcessed, limit)
+}
@@ -0,0 +1,20 @@
+package sqlitestorage
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/storage"
+	"github.com/superles/yapgofermart/internal/storage/storagetest"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := NewStorage(filepath.Join(t.TempDir(), "gophermart.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, s.Close())
+		})
+		return s
+	})
+}
@@ -0,0 +1,128 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"github.com/superles/yapgofermart/internal/utils/logger"
+	"io/fs"
+	_ "modernc.org/sqlite"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// uriPathEscaper экранирование символов пути, которые SQLite иначе разберёт как часть file: URI
+var uriPathEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// SqliteStorage хранилище в файле SQLite, все запросы выполняются через одно соединение,
+// поэтому транзакции сериализуются так же, как блокировки строк в pgstorage
+type SqliteStorage struct {
+	db *sql.DB
+}
+
+// NewStorage открытие файла бд по пути path (":memory:" - бд в памяти) и применение миграций
+func NewStorage(path string) (*SqliteStorage, error) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", uriPathEscaper.Replace(path)))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SqliteStorage{db}, nil
+}
+
+func (s *SqliteStorage) Close() error {
+	return s.db.Close()
+}
+
+// migrate применение встроенных миграций NNNN_name.sql, версия схемы хранится в PRAGMA user_version
+func migrate(ctx context.Context, db *sql.DB) error {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	var current int
+	if err := db.QueryRowContext(ctx, "pragma user_version").Scan(&current); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("неверное имя файла миграции %s", entry.Name())
+		}
+		if version <= current {
+			continue
+		}
+		data, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		err = withTx(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(data)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("pragma user_version = %d", version))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка применения миграции %s: %w", entry.Name(), err)
+		}
+		logger.FromContext(ctx).Infof("применена миграция %s", entry.Name())
+	}
+	return nil
+}
+
+// withTx выполнение fn в транзакции с откатом при ошибке
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть транзакцию: %w", err)
+	}
+
+	defer func(tx *sql.Tx) {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			logger.FromContext(ctx).Error(fmt.Sprintf("rollback error: %s", err))
+		}
+	}(tx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// toUnix время в формате хранения бд
+func toUnix(t time.Time) int64 {
+	return t.UnixNano()
+}
+
+// fromUnix время из формата хранения бд
+func fromUnix(n int64) time.Time {
+	return time.Unix(0, n)
+}
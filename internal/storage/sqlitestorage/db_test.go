@@ -0,0 +1,68 @@
+package sqlitestorage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSqliteStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "gophermart.db")
+	s, err := NewStorage(path)
+	require.NoError(t, err, "ошибка инициализации хранилища")
+
+	user, err := s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+	other, err := s.RegisterUser(ctx, model.User{Name: "user1", PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err)
+
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+	assert.ErrorIs(t, s.CreateNewOrder(ctx, "12345678903", user.ID), errs.ErrExistsSameUser)
+	assert.ErrorIs(t, s.CreateNewOrder(ctx, "12345678903", other.ID), errs.ErrExistsAnotherUser)
+
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+	assert.ErrorIs(t, storage.CreditOrder(ctx, s, "12345678903", 100), errs.ErrOrderFinal, "повторное начисление")
+
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- storage.Withdraw(ctx, s, "2377225624", 30, user.ID)
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var ok, denied int
+	for err := range results {
+		if err == nil {
+			ok++
+		} else {
+			assert.ErrorIs(t, err, errs.ErrWithdrawalNotEnoughBalance)
+			denied++
+		}
+	}
+	assert.Equal(t, 3, ok)
+	assert.Equal(t, 2, denied)
+
+	require.NoError(t, s.Close())
+
+	// повторное открытие не применяет миграции заново и сохраняет данные
+	s, err = NewStorage(path)
+	require.NoError(t, err)
+	defer s.Close()
+	got, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 10, got.Balance, 0.001)
+	withdrawn, err := s.GetWithdrawnSumByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 90, withdrawn, 0.001)
+}
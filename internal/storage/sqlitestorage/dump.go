@@ -0,0 +1,126 @@
+package sqlitestorage
+
+import (
+	"context"
+	"errors"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// Выгрузка читает записи целиком до вызовов fn: пока строки запроса открыты,
+// единственное соединение занято и fn не смогла бы обратиться к хранилищу
+
+func (s *SqliteStorage) ExportUsers(ctx context.Context, fn func(user model.User) error) error {
+	rows, err := s.db.QueryContext(ctx, `select id, name, password_hash, coalesce(role, ''), coalesce(balance, 0), withdrawn_total from users order by id`)
+	if err != nil {
+		return err
+	}
+	var items []model.User
+	for rows.Next() {
+		var item model.User
+		if err := rows.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SqliteStorage) ExportOrders(ctx context.Context, fn func(order model.Order) error) error {
+	items, err := s.queryOrders(ctx, `select `+orderColumns+` from orders order by uploaded_at, number`)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SqliteStorage) ExportWithdrawals(ctx context.Context, fn func(withdrawal model.Withdrawal) error) error {
+	rows, err := s.db.QueryContext(ctx, `select order_number, user_id, coalesce(sum, 0), processed_at from withdrawals order by id`)
+	if err != nil {
+		return err
+	}
+	var items []model.Withdrawal
+	for rows.Next() {
+		var item model.Withdrawal
+		var processedAt int64
+		if err := rows.Scan(&item.Order, &item.UserID, &item.Sum, &processedAt); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		item.ProcessedAt = fromUnix(processedAt)
+		items = append(items, item)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SqliteStorage) ImportUser(ctx context.Context, user model.User) error {
+	_, err := s.db.ExecContext(ctx, `insert into users (id, name, password_hash, role, balance, withdrawn_total) values (?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Name, user.PasswordHash, user.Role, user.Balance, user.Withdrawn)
+	if isUniqueViolation(err) {
+		return errs.ErrUserExists
+	}
+	return err
+}
+
+func (s *SqliteStorage) ImportOrder(ctx context.Context, order model.Order) error {
+	_, err := s.db.ExecContext(ctx, `insert into orders (number, status, accrual, uploaded_at, user_id, accrual_attempts) values (?, ?, ?, ?, ?, ?)`,
+		order.Number, order.Status, order.Accrual, toUnix(order.UploadedAt), order.UserID, order.Attempts)
+	if isUniqueViolation(err) {
+		existing, getErr := s.GetOrder(ctx, order.Number)
+		if getErr == nil && existing.UserID != order.UserID {
+			return errs.ErrExistsAnotherUser
+		}
+		return errs.ErrExistsSameUser
+	}
+	return err
+}
+
+func (s *SqliteStorage) ImportWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	processedAt := toUnix(withdrawal.ProcessedAt)
+	result, err := s.db.ExecContext(ctx, `insert into withdrawals (order_number, user_id, sum, processed_at) select ?, ?, ?, ?
+		where not exists (select 1 from withdrawals where order_number=? and user_id=? and processed_at=?)`,
+		withdrawal.Order, withdrawal.UserID, withdrawal.Sum, processedAt, withdrawal.Order, withdrawal.UserID, processedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errs.ErrWithdrawalExists
+	}
+	return nil
+}
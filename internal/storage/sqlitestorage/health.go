@@ -0,0 +1,47 @@
+package sqlitestorage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Ping проверка соединения с файлом бд
+func (s *SqliteStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// SchemaVersion версия схемы из PRAGMA user_version и последняя встроенная версия
+func (s *SqliteStorage) SchemaVersion(ctx context.Context) (int64, int64, error) {
+	var current int64
+	if err := s.db.QueryRowContext(ctx, "pragma user_version").Scan(&current); err != nil {
+		return 0, 0, err
+	}
+	latest, err := latestVersion()
+	if err != nil {
+		return 0, 0, err
+	}
+	return current, latest, nil
+}
+
+// latestVersion версия последней встроенной миграции
+func latestVersion() (int64, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("неверное имя файла миграции %s", entry.Name())
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,49 @@
+create table if not exists users
+(
+    id            integer primary key autoincrement,
+    name          text not null,
+    password_hash text not null,
+    role          text,
+    balance       real
+);
+
+create table if not exists orders
+(
+    number           text    not null primary key,
+    status           text    not null,
+    accrual          real,
+    uploaded_at      integer not null,
+    accrual_check_at integer,
+    accrual_status   text,
+    accrual_attempts integer not null default 0,
+    queued_at        integer,
+    user_id          integer not null
+);
+
+create index if not exists orders_user_id_idx on orders (user_id);
+
+create index if not exists orders_status_idx on orders (status);
+
+create table if not exists withdrawals
+(
+    id           integer primary key autoincrement,
+    order_number text    not null,
+    user_id      integer not null,
+    sum          real,
+    processed_at integer not null
+);
+
+create index if not exists withdrawals_user_id_idx on withdrawals (user_id);
+
+create table if not exists accrual_log
+(
+    id           integer primary key autoincrement,
+    order_number text    not null,
+    status       text,
+    accrual      real,
+    http_code    integer not null,
+    error        text,
+    created_at   integer not null
+);
+
+create index if not exists accrual_log_order_number_idx on accrual_log (order_number);
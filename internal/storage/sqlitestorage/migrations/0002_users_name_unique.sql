@@ -0,0 +1,6 @@
+-- Уникальный индекс по имени пользователя.
+-- Дубликаты не удаляются автоматически: на них могут ссылаться заказы и списания. Если они есть,
+-- миграция прерывается ошибкой "UNIQUE constraint failed: users.name", найти их можно запросом:
+--   select name, count(*) from users group by name having count(*) > 1;
+create unique index if not exists users_name_uindex
+    on users (name);
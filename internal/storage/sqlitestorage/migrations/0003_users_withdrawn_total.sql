@@ -0,0 +1,5 @@
+alter table users
+    add column withdrawn_total real not null default 0;
+
+update users
+set withdrawn_total = coalesce((select sum(w.sum) from withdrawals w where w.user_id = users.id), 0);
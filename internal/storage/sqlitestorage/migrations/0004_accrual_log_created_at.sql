@@ -0,0 +1 @@
+create index if not exists accrual_log_created_at_idx on accrual_log (created_at);
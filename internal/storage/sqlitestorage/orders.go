@@ -0,0 +1,145 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"time"
+)
+
+const orderColumns = `number, status, accrual, uploaded_at, user_id, accrual_attempts`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row scanner) (model.Order, error) {
+	var item model.Order
+	var uploadedAt int64
+	if err := row.Scan(&item.Number, &item.Status, &item.Accrual, &uploadedAt, &item.UserID, &item.Attempts); err != nil {
+		return item, err
+	}
+	item.UploadedAt = fromUnix(uploadedAt)
+	return item, nil
+}
+
+func (s *SqliteStorage) queryOrders(ctx context.Context, query string, args ...any) ([]model.Order, error) {
+	var items []model.Order
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scanOrder(rows)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (s *SqliteStorage) GetOrder(ctx context.Context, number string) (model.Order, error) {
+	item, err := scanOrder(s.db.QueryRowContext(ctx, `select `+orderColumns+` from orders where number=?`, number))
+	if errors.Is(err, sql.ErrNoRows) {
+		return item, errs.ErrNoRows
+	}
+	return item, err
+}
+
+func (s *SqliteStorage) GetAllOrdersByUser(ctx context.Context, userID int64) ([]model.Order, error) {
+	return s.queryOrders(ctx, `select `+orderColumns+` from orders where user_id=? order by uploaded_at desc`, userID)
+}
+
+// GetAllNewAndProcessingOrders получение всех заказов со статусами NEW и PROCESSING для запроса/повторного запроса в системе лояльности(accrual)
+func (s *SqliteStorage) GetAllNewAndProcessingOrders(ctx context.Context) ([]model.Order, error) {
+	return s.queryOrders(ctx, `select `+orderColumns+` from orders where status=? or status=? order by uploaded_at asc`, model.OrderStatusNew, model.OrderStatusProcessing)
+}
+
+func (s *SqliteStorage) CreateNewOrder(ctx context.Context, number string, userID int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		item, err := scanOrder(tx.QueryRowContext(ctx, `select `+orderColumns+` from orders where number=?`, number))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if len(item.Number) > 0 && item.UserID != userID {
+			//Если существует и пользователь не совпадает
+			return errs.ErrExistsAnotherUser
+		} else if len(item.Number) > 0 && item.UserID == userID {
+			//Если существует и пользователь совпадает
+			return errs.ErrExistsSameUser
+		}
+
+		_, err = tx.ExecContext(ctx, "insert into orders (number, status, user_id, uploaded_at) values (?, ?, ?, ?)", number, model.OrderStatusNew, userID, toUnix(time.Now()))
+		return err
+	})
+}
+
+func (s *SqliteStorage) UpdateOrderStatus(ctx context.Context, number string, status string) error {
+	res, err := s.db.ExecContext(ctx, "update orders set status=? where number=? and status in (?, ?, ?)",
+		status, number, model.OrderStatusNew, model.OrderStatusProcessing, model.OrderStatusStuck)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); !errors.Is(err, errs.ErrNoRows) {
+		return err
+	}
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "select exists(select 1 from orders where number=?)", number).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return errs.ErrOrderFinal
+	}
+	return errs.ErrNoRows
+}
+
+// IncrementOrderAttempts учёт очередного запроса заказа в систему расчёта
+func (s *SqliteStorage) IncrementOrderAttempts(ctx context.Context, number string) error {
+	res, err := s.db.ExecContext(ctx, "update orders set accrual_attempts=accrual_attempts + 1, accrual_check_at=? where number=?", toUnix(time.Now()), number)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// MarkStuckOrders перевод в статус STUCK заказов NEW и PROCESSING, ожидающих дольше maxAge или опрошенных не менее maxAttempts раз, нулевое значение отключает критерий
+func (s *SqliteStorage) MarkStuckOrders(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `update orders set status=? where (status=? or status=?)
+		and ((? > 0 and coalesce(queued_at, uploaded_at) < ?) or (? > 0 and accrual_attempts >= ?))`,
+		model.OrderStatusStuck, model.OrderStatusNew, model.OrderStatusProcessing,
+		int64(maxAge), toUnix(time.Now().Add(-maxAge)), maxAttempts, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SqliteStorage) GetAllStuckOrders(ctx context.Context) ([]model.Order, error) {
+	return s.queryOrders(ctx, `select `+orderColumns+` from orders where status=? order by uploaded_at asc`, model.OrderStatusStuck)
+}
+
+// RequeueStuckOrder возврат заказа из статуса STUCK в очередь опроса со сбросом попыток
+func (s *SqliteStorage) RequeueStuckOrder(ctx context.Context, number string) error {
+	res, err := s.db.ExecContext(ctx, "update orders set status=?, accrual_attempts=0, queued_at=? where number=? and status=?", model.OrderStatusNew, toUnix(time.Now()), number, model.OrderStatusStuck)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errs.ErrNoRows
+	}
+	return nil
+}
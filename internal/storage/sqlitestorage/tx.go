@@ -0,0 +1,74 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"time"
+)
+
+// sqliteTx реализация storage.Tx, отдельная блокировка строк не нужна:
+// единственное соединение сериализует транзакции целиком
+type sqliteTx struct {
+	tx *sql.Tx
+}
+
+func (s *SqliteStorage) WithTx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&sqliteTx{tx})
+	})
+}
+
+func (t *sqliteTx) GetUserForUpdate(ctx context.Context, id int64) (model.User, error) {
+	item := model.User{}
+
+	row := t.tx.QueryRowContext(ctx, `select id, name, password_hash, coalesce(role, ''), coalesce(balance, 0), withdrawn_total from users where id=?`, id)
+
+	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return item, errs.ErrNoRows
+		}
+		return item, err
+	}
+
+	return item, nil
+}
+
+func (t *sqliteTx) GetOrderForUpdate(ctx context.Context, number string) (model.Order, error) {
+	item, err := scanOrder(t.tx.QueryRowContext(ctx, `select `+orderColumns+` from orders where number=?`, number))
+	if errors.Is(err, sql.ErrNoRows) {
+		return item, errs.ErrNoRows
+	}
+	return item, err
+}
+
+func (t *sqliteTx) UpdateUserBalance(ctx context.Context, id int64, balance float64) error {
+	res, err := t.tx.ExecContext(ctx, "update users set balance=? where id=?", balance, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t *sqliteTx) UpdateOrder(ctx context.Context, order model.Order) error {
+	res, err := t.tx.ExecContext(ctx, "update orders set status=?, accrual=?, accrual_attempts=? where number=?", order.Status, order.Accrual, order.Attempts, order.Number)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t *sqliteTx) AddWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error {
+	processedAt := withdrawal.ProcessedAt
+	if processedAt.IsZero() {
+		processedAt = time.Now()
+	}
+	if _, err := t.tx.ExecContext(ctx, "insert into withdrawals (order_number, user_id, sum, processed_at) values (?, ?, ?, ?)", withdrawal.Order, withdrawal.UserID, withdrawal.Sum, toUnix(processedAt)); err != nil {
+		return err
+	}
+	_, err := t.tx.ExecContext(ctx, "update users set withdrawn_total=withdrawn_total + ? where id=?", withdrawal.Sum, withdrawal.UserID)
+	return err
+}
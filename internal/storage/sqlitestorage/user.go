@@ -0,0 +1,52 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+)
+
+func (s *SqliteStorage) GetUserByID(ctx context.Context, id int64) (model.User, error) {
+
+	item := model.User{}
+
+	row := s.db.QueryRowContext(ctx, `select id, name, password_hash, coalesce(role, ''), coalesce(balance, 0), withdrawn_total from users where id=?`, id)
+
+	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return item, errs.ErrNoRows
+		}
+		return item, err
+	}
+
+	return item, nil
+}
+
+func (s *SqliteStorage) GetUserByName(ctx context.Context, name string) (model.User, error) {
+
+	item := model.User{}
+
+	row := s.db.QueryRowContext(ctx, `select id, name, password_hash, coalesce(role, ''), coalesce(balance, 0), withdrawn_total from users where name=?`, name)
+
+	if err := row.Scan(&item.ID, &item.Name, &item.PasswordHash, &item.Role, &item.Balance, &item.Withdrawn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return item, errs.ErrNoRows
+		}
+		return item, err
+	}
+
+	return item, nil
+}
+
+func (s *SqliteStorage) RegisterUser(ctx context.Context, data model.User) (model.User, error) {
+	_, err := s.db.ExecContext(ctx, "insert into users (name, password_hash, role) values (?, ?, ?)", data.Name, data.PasswordHash, data.Role)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return data, errs.ErrUserExists
+		}
+		return data, err
+	}
+	return s.GetUserByName(ctx, data.Name)
+}
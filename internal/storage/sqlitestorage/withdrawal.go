@@ -0,0 +1,38 @@
+package sqlitestorage
+
+import (
+	"context"
+	"github.com/superles/yapgofermart/internal/model"
+)
+
+func (s *SqliteStorage) GetAllWithdrawalsByUserID(ctx context.Context, id int64) ([]model.Withdrawal, error) {
+
+	var items []model.Withdrawal
+	rows, err := s.db.QueryContext(ctx, `select order_number, user_id, sum, processed_at from withdrawals where user_id=? order by processed_at desc`, id)
+	if err != nil {
+		return items, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.Withdrawal
+		var processedAt int64
+		err = rows.Scan(&item.Order, &item.UserID, &item.Sum, &processedAt)
+		if err != nil {
+			return items, err
+		}
+		item.ProcessedAt = fromUnix(processedAt)
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (s *SqliteStorage) GetWithdrawnSumByUserID(ctx context.Context, userID int64) (float64, error) {
+	var returnSum float64
+	// сумма поддерживается в users.withdrawn_total при каждом списании
+	row := s.db.QueryRowContext(ctx, "select coalesce((select withdrawn_total from users where id=?), 0)", userID)
+	if err := row.Scan(&returnSum); err != nil {
+		return 0, err
+	}
+	return returnSum, nil
+}
@@ -4,4 +4,7 @@ type Storage interface {
 	UserStorage
 	OrderStorage
 	WithdrawalStorage
+	AccrualLogStorage
+	Transactor
+	DumpStorage
 }
@@ -0,0 +1,529 @@
+// Package storagetest общий набор тестов поведения для реализаций storage.Storage
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+	"github.com/superles/yapgofermart/internal/storage"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory создание пустого хранилища для очередного теста
+type Factory func(t *testing.T) storage.Storage
+
+// tick пауза между записями, чтобы время загрузки различалось во всех хранилищах
+const tick = 5 * time.Millisecond
+
+// Run запуск набора тестов для хранилища, каждый тест получает новое хранилище от newStorage
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"#1 users", testUsers},
+		{"#2 order duplicates", testOrderDuplicates},
+		{"#3 order ordering", testOrderOrdering},
+		{"#4 order status", testOrderStatus},
+		{"#5 accrual idempotency", testAccrualIdempotency},
+		{"#6 concurrent withdrawals", testConcurrentWithdrawals},
+		{"#7 withdrawal ordering", testWithdrawalOrdering},
+		{"#8 stuck orders", testStuckOrders},
+		{"#9 accrual log", testAccrualLog},
+		{"#10 transaction commit and rollback", testTxCommitRollback},
+		{"#11 concurrent transactions", testTxConcurrent},
+		{"#12 import and export", testImportExport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func registerUser(t *testing.T, s storage.Storage, name string) model.User {
+	user, err := s.RegisterUser(context.Background(), model.User{Name: name, PasswordHash: "hash", Role: model.RoleUser})
+	require.NoError(t, err, "ошибка регистрации пользователя")
+	return user
+}
+
+// createOrders создание заказов в порядке numbers с различающимся временем загрузки
+func createOrders(t *testing.T, s storage.Storage, userID int64, numbers ...string) {
+	for _, number := range numbers {
+		require.NoError(t, s.CreateNewOrder(context.Background(), number, userID))
+		time.Sleep(tick)
+	}
+}
+
+// withoutHash пользователь без хеша пароля, который GetUserByID может не возвращать
+func withoutHash(user model.User) model.User {
+	user.PasswordHash = ""
+	return user
+}
+
+func orderNumbers(orders []model.Order) []string {
+	numbers := make([]string, 0, len(orders))
+	for _, order := range orders {
+		numbers = append(numbers, order.Number)
+	}
+	return numbers
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	assert.NotZero(t, user.ID)
+	assert.Equal(t, "hash", user.PasswordHash)
+	assert.Equal(t, model.RoleUser, user.Role)
+	assert.Zero(t, user.Balance)
+
+	byID, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, withoutHash(user), withoutHash(byID))
+
+	byName, err := s.GetUserByName(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, user, byName)
+
+	_, err = s.RegisterUser(ctx, model.User{Name: "user", PasswordHash: "other", Role: model.RoleUser})
+	assert.ErrorIs(t, err, errs.ErrUserExists, "повторная регистрация имени")
+
+	_, err = s.GetUserByName(ctx, "unknown")
+	assert.ErrorIs(t, err, errs.ErrNoRows)
+	_, err = s.GetUserByID(ctx, user.ID+100)
+	assert.ErrorIs(t, err, errs.ErrNoRows)
+}
+
+func testOrderDuplicates(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	other := registerUser(t, s, "other")
+
+	require.NoError(t, s.CreateNewOrder(ctx, "12345678903", user.ID))
+	assert.ErrorIs(t, s.CreateNewOrder(ctx, "12345678903", user.ID), errs.ErrExistsSameUser)
+	assert.ErrorIs(t, s.CreateNewOrder(ctx, "12345678903", other.ID), errs.ErrExistsAnotherUser)
+
+	order, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusNew, order.Status)
+	assert.Equal(t, user.ID, order.UserID)
+	assert.Nil(t, order.Accrual)
+	assert.Zero(t, order.Attempts)
+	assert.False(t, order.UploadedAt.IsZero())
+
+	_, err = s.GetOrder(ctx, "2377225624")
+	assert.ErrorIs(t, err, errs.ErrNoRows)
+}
+
+func testOrderOrdering(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	other := registerUser(t, s, "other")
+	createOrders(t, s, user.ID, "12345678903", "2377225624", "79927398713")
+	createOrders(t, s, other.ID, "4561261212345467")
+
+	orders, err := s.GetAllOrdersByUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"79927398713", "2377225624", "12345678903"}, orderNumbers(orders), "заказы пользователя от новых к старым")
+
+	orders, err = s.GetAllOrdersByUser(ctx, user.ID+100)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+
+	require.NoError(t, storage.CreditOrder(ctx, s, "2377225624", 10))
+	require.NoError(t, s.UpdateOrderStatus(ctx, "79927398713", model.OrderStatusProcessing))
+
+	orders, err = s.GetAllNewAndProcessingOrders(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"12345678903", "79927398713", "4561261212345467"}, orderNumbers(orders), "очередь опроса от старых к новым")
+}
+
+func testOrderStatus(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	createOrders(t, s, user.ID, "12345678903")
+
+	require.NoError(t, s.UpdateOrderStatus(ctx, "12345678903", model.OrderStatusProcessing))
+	order, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusProcessing, order.Status)
+
+	assert.ErrorIs(t, s.UpdateOrderStatus(ctx, "2377225624", model.OrderStatusProcessing), errs.ErrNoRows)
+
+	// повторный ответ PROCESSING не возвращает обработанный заказ в опрос и не даёт начислить баллы ещё раз
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+	assert.ErrorIs(t, s.UpdateOrderStatus(ctx, "12345678903", model.OrderStatusProcessing), errs.ErrOrderFinal)
+	assert.ErrorIs(t, storage.CreditOrder(ctx, s, "12345678903", 100), errs.ErrOrderFinal)
+	order, err = s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusProcessed, order.Status)
+	user, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), user.Balance)
+}
+
+func testAccrualIdempotency(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	createOrders(t, s, user.ID, "12345678903", "2377225624")
+
+	assert.Error(t, storage.CreditOrder(ctx, s, "12345678903", -1), "отрицательное начисление")
+
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- storage.CreditOrder(ctx, s, "12345678903", 100)
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var applied int
+	for err := range results {
+		if err == nil {
+			applied++
+		} else {
+			assert.ErrorIs(t, err, errs.ErrOrderFinal)
+		}
+	}
+	assert.Equal(t, 1, applied, "начисление применяется один раз")
+	assert.ErrorIs(t, storage.CreditOrder(ctx, s, "12345678903", 100), errs.ErrOrderFinal, "повторное начисление")
+
+	require.NoError(t, s.UpdateOrderStatus(ctx, "2377225624", model.OrderStatusInvalid))
+	assert.ErrorIs(t, storage.CreditOrder(ctx, s, "2377225624", 100), errs.ErrOrderFinal, "начисление по INVALID заказу")
+	assert.ErrorIs(t, storage.CreditOrder(ctx, s, "79927398713", 100), errs.ErrNoRows, "начисление по неизвестному заказу")
+
+	order, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusProcessed, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, float64(100), *order.Accrual)
+
+	user, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), user.Balance)
+}
+
+func testConcurrentWithdrawals(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	createOrders(t, s, user.ID, "12345678903")
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results <- storage.Withdraw(ctx, s, fmt.Sprintf("withdraw-%d", i), 30, user.ID)
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	var ok, denied int
+	for err := range results {
+		if err == nil {
+			ok++
+		} else {
+			assert.ErrorIs(t, err, errs.ErrWithdrawalNotEnoughBalance)
+			denied++
+		}
+	}
+	assert.Equal(t, 3, ok)
+	assert.Equal(t, 2, denied)
+
+	user, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 10, user.Balance, 0.001)
+	assert.InDelta(t, 90, user.Withdrawn, 0.001)
+
+	withdrawn, err := s.GetWithdrawnSumByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 90, withdrawn, 0.001)
+
+	assert.ErrorIs(t, storage.Withdraw(ctx, s, "2377225624", 30, user.ID+100), errs.ErrNoRows, "списание неизвестного пользователя")
+}
+
+func testWithdrawalOrdering(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	other := registerUser(t, s, "other")
+	createOrders(t, s, user.ID, "12345678903")
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 100))
+
+	for _, number := range []string{"2377225624", "79927398713", "4561261212345467"} {
+		require.NoError(t, storage.Withdraw(ctx, s, number, 10, user.ID))
+		time.Sleep(tick)
+	}
+
+	withdrawals, err := s.GetAllWithdrawalsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	var numbers []string
+	for _, withdrawal := range withdrawals {
+		assert.Equal(t, user.ID, withdrawal.UserID)
+		numbers = append(numbers, withdrawal.Order)
+	}
+	assert.Equal(t, []string{"4561261212345467", "79927398713", "2377225624"}, numbers, "списания от новых к старым")
+
+	withdrawals, err = s.GetAllWithdrawalsByUserID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, withdrawals)
+
+	withdrawn, err := s.GetWithdrawnSumByUserID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Zero(t, withdrawn)
+}
+
+func testStuckOrders(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	createOrders(t, s, user.ID, "12345678903", "2377225624")
+
+	require.NoError(t, s.IncrementOrderAttempts(ctx, "12345678903"))
+	require.NoError(t, s.IncrementOrderAttempts(ctx, "12345678903"))
+	assert.ErrorIs(t, s.IncrementOrderAttempts(ctx, "79927398713"), errs.ErrNoRows)
+
+	count, err := s.MarkStuckOrders(ctx, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	stuck, err := s.GetAllStuckOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, stuck, 1)
+	assert.Equal(t, "12345678903", stuck[0].Number)
+	assert.Equal(t, 2, stuck[0].Attempts)
+
+	queue, err := s.GetAllNewAndProcessingOrders(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2377225624"}, orderNumbers(queue))
+
+	assert.ErrorIs(t, s.RequeueStuckOrder(ctx, "2377225624"), errs.ErrNoRows, "возврат в очередь не STUCK заказа")
+	require.NoError(t, s.RequeueStuckOrder(ctx, "12345678903"))
+
+	order, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusNew, order.Status)
+	assert.Zero(t, order.Attempts)
+
+	// возвращённый в очередь заказ отсчитывает возраст заново
+	count, err = s.MarkStuckOrders(ctx, time.Hour, 0)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func testAccrualLog(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	createOrders(t, s, user.ID, "12345678903", "2377225624", "79927398713")
+	require.NoError(t, storage.CreditOrder(ctx, s, "12345678903", 10))
+	require.NoError(t, storage.CreditOrder(ctx, s, "2377225624", 20))
+
+	sum := float64(10)
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "12345678903", Status: "PROCESSING", HTTPCode: 200}))
+	time.Sleep(tick)
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "12345678903", Status: "PROCESSED", Accrual: &sum, HTTPCode: 200}))
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "2377225624", HTTPCode: 500, Error: "internal server error"}))
+
+	logs, err := s.GetAccrualLogsByOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "PROCESSING", logs[0].Status)
+	assert.Nil(t, logs[0].Accrual)
+	assert.Equal(t, "PROCESSED", logs[1].Status)
+	require.NotNil(t, logs[1].Accrual)
+	assert.Equal(t, sum, *logs[1].Accrual)
+	assert.False(t, logs[1].CreatedAt.IsZero())
+
+	logs, err = s.GetAccrualLogsByOrder(ctx, "2377225624")
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, 500, logs[0].HTTPCode)
+	assert.Equal(t, "internal server error", logs[0].Error)
+
+	orders, err := s.GetRandomProcessedOrders(ctx, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"12345678903", "2377225624"}, orderNumbers(orders))
+
+	orders, err = s.GetRandomProcessedOrders(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, orders, 1)
+
+	count, err := s.DeleteOldAccrualLogs(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	time.Sleep(2 * tick)
+	require.NoError(t, s.AddAccrualLog(ctx, model.AccrualLog{Order: "79927398713", Status: "PROCESSING", HTTPCode: 200}))
+	count, err = s.DeleteOldAccrualLogs(ctx, tick)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count, "удаляются только старые записи")
+	logs, err = s.GetAccrualLogsByOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+	logs, err = s.GetAccrualLogsByOrder(ctx, "79927398713")
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
+
+func testTxCommitRollback(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+	createOrders(t, s, user.ID, "12345678903")
+
+	sum := float64(50)
+	err := s.WithTx(ctx, func(tx storage.Tx) error {
+		order, err := tx.GetOrderForUpdate(ctx, "12345678903")
+		if err != nil {
+			return err
+		}
+		order.Status = model.OrderStatusProcessed
+		order.Accrual = &sum
+		if err := tx.UpdateOrder(ctx, order); err != nil {
+			return err
+		}
+		if err := tx.UpdateUserBalance(ctx, user.ID, sum); err != nil {
+			return err
+		}
+		// чтение внутри транзакции видит её изменения
+		changed, err := tx.GetUserForUpdate(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, sum, changed.Balance)
+		return tx.AddWithdrawal(ctx, model.Withdrawal{UserID: user.ID, Order: "2377225624", Sum: 5})
+	})
+	require.NoError(t, err)
+
+	order, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusProcessed, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, sum, *order.Accrual)
+	withdrawn, err := s.GetWithdrawnSumByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(5), withdrawn)
+
+	errRollback := errors.New("откат")
+	err = s.WithTx(ctx, func(tx storage.Tx) error {
+		if err := tx.UpdateUserBalance(ctx, user.ID, 1000); err != nil {
+			return err
+		}
+		if err := tx.AddWithdrawal(ctx, model.Withdrawal{UserID: user.ID, Order: "79927398713", Sum: 5}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	user, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sum, user.Balance, "изменения отменённой транзакции не применяются")
+	assert.Equal(t, float64(5), user.Withdrawn)
+	withdrawn, err = s.GetWithdrawnSumByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(5), withdrawn)
+
+	err = s.WithTx(ctx, func(tx storage.Tx) error {
+		_, err := tx.GetUserForUpdate(ctx, user.ID+100)
+		assert.ErrorIs(t, err, errs.ErrNoRows)
+		_, err = tx.GetOrderForUpdate(ctx, "79927398713")
+		assert.ErrorIs(t, err, errs.ErrNoRows)
+		assert.ErrorIs(t, tx.UpdateUserBalance(ctx, user.ID+100, 1), errs.ErrNoRows)
+		assert.ErrorIs(t, tx.UpdateOrder(ctx, model.Order{Number: "79927398713", Status: model.OrderStatusNew}), errs.ErrNoRows)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testTxConcurrent(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := registerUser(t, s, "user")
+
+	// чтение с блокировкой исключает потерю параллельных изменений баланса
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.WithTx(ctx, func(tx storage.Tx) error {
+				current, err := tx.GetUserForUpdate(ctx, user.ID)
+				if err != nil {
+					return err
+				}
+				return tx.UpdateUserBalance(ctx, user.ID, current.Balance+10)
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	user, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), user.Balance)
+}
+
+func testImportExport(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	// время с точностью до микросекунд переносится без потерь во всех хранилищах
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	accrual := 150.5
+	user := model.User{ID: 42, Name: "imported", PasswordHash: "hash", Role: model.RoleAdmin, Balance: 120.5, Withdrawn: 30}
+	order := model.Order{Number: "12345678903", Status: model.OrderStatusProcessed, Accrual: &accrual, UploadedAt: at, UserID: user.ID, Attempts: 2}
+	withdrawal := model.Withdrawal{UserID: user.ID, Order: "2377225624", Sum: 30, ProcessedAt: at.Add(time.Hour)}
+
+	require.NoError(t, s.ImportUser(ctx, user))
+	require.NoError(t, s.ImportOrder(ctx, order))
+	require.NoError(t, s.ImportWithdrawal(ctx, withdrawal))
+
+	assert.ErrorIs(t, s.ImportUser(ctx, user), errs.ErrUserExists, "повторная загрузка пользователя")
+	assert.ErrorIs(t, s.ImportOrder(ctx, order), errs.ErrExistsSameUser, "повторная загрузка заказа")
+	assert.ErrorIs(t, s.ImportWithdrawal(ctx, withdrawal), errs.ErrWithdrawalExists, "повторная загрузка списания")
+
+	got, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, withoutHash(user), withoutHash(got))
+	got, err = s.GetUserByName(ctx, user.Name)
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	registered := registerUser(t, s, "registered")
+	assert.Greater(t, registered.ID, user.ID, "новые пользователи получают id после загруженных")
+
+	var users []model.User
+	require.NoError(t, s.ExportUsers(ctx, func(u model.User) error {
+		users = append(users, u)
+		return nil
+	}))
+	assert.Equal(t, []model.User{user, registered}, users)
+
+	var orders []model.Order
+	require.NoError(t, s.ExportOrders(ctx, func(o model.Order) error {
+		orders = append(orders, o)
+		return nil
+	}))
+	require.Len(t, orders, 1)
+	assert.True(t, at.Equal(orders[0].UploadedAt), "время загрузки заказа %s", orders[0].UploadedAt)
+	orders[0].UploadedAt = at
+	assert.Equal(t, order, orders[0])
+
+	var withdrawals []model.Withdrawal
+	require.NoError(t, s.ExportWithdrawals(ctx, func(w model.Withdrawal) error {
+		withdrawals = append(withdrawals, w)
+		return nil
+	}))
+	require.Len(t, withdrawals, 1)
+	assert.True(t, withdrawal.ProcessedAt.Equal(withdrawals[0].ProcessedAt), "время списания %s", withdrawals[0].ProcessedAt)
+	withdrawals[0].ProcessedAt = withdrawal.ProcessedAt
+	assert.Equal(t, withdrawal, withdrawals[0])
+
+	stop := errors.New("stop")
+	assert.ErrorIs(t, s.ExportUsers(ctx, func(model.User) error { return stop }), stop, "ошибка fn прерывает выгрузку")
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"github.com/superles/yapgofermart/internal/model"
+)
+
+// Tx операции внутри транзакции хранилища. Чтения ForUpdate блокируют строку до конца транзакции,
+// поэтому изменения, вычисленные из прочитанного значения, не теряются при параллельных транзакциях
+type Tx interface {
+	GetUserForUpdate(ctx context.Context, id int64) (model.User, error)
+	GetOrderForUpdate(ctx context.Context, number string) (model.Order, error)
+	UpdateUserBalance(ctx context.Context, id int64, balance float64) error
+	// UpdateOrder сохранение статуса, начисления и количества попыток заказа
+	UpdateOrder(ctx context.Context, order model.Order) error
+	// AddWithdrawal запись списания, при нулевом ProcessedAt используется текущее время
+	AddWithdrawal(ctx context.Context, withdrawal model.Withdrawal) error
+}
+
+type Transactor interface {
+	// WithTx выполнение fn в транзакции: изменения фиксируются, если fn вернула nil, иначе откатываются.
+	// Внутри fn хранилище используется только через tx
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// CreditOrder перевод заказа в PROCESSED с начислением sum баллов его владельцу в одной транзакции.
+// Заказ в конечном статусе не меняется и возвращается errs.ErrOrderFinal, поэтому баллы начисляются один раз
+func CreditOrder(ctx context.Context, t Transactor, number string, sum float64) error {
+	if sum < 0 {
+		return errors.New("невозможно начислить отрицательную сумму в качестве бонусов")
+	}
+
+	return t.WithTx(ctx, func(tx Tx) error {
+		order, err := tx.GetOrderForUpdate(ctx, number)
+		if err != nil {
+			return err
+		}
+		if order.Status == model.OrderStatusProcessed || order.Status == model.OrderStatusInvalid {
+			return errs.ErrOrderFinal
+		}
+
+		user, err := tx.GetUserForUpdate(ctx, order.UserID)
+		if err != nil {
+			return err
+		}
+
+		order.Status = model.OrderStatusProcessed
+		order.Accrual = &sum
+		if err := tx.UpdateOrder(ctx, order); err != nil {
+			return err
+		}
+		return tx.UpdateUserBalance(ctx, user.ID, user.Balance+sum)
+	})
+}
+
+// Withdraw списание баллов пользователя в счёт заказа, при нехватке баланса - errs.ErrWithdrawalNotEnoughBalance
+func Withdraw(ctx context.Context, t Transactor, number string, sum float64, userID int64) error {
+	if sum <= 0 {
+		return errors.New("сумма списания должна быть положительной")
+	}
+
+	return t.WithTx(ctx, func(tx Tx) error {
+		user, err := tx.GetUserForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user.Balance-sum < 0 {
+			return errs.ErrWithdrawalNotEnoughBalance
+		}
+
+		if err := tx.UpdateUserBalance(ctx, userID, user.Balance-sum); err != nil {
+			return err
+		}
+		return tx.AddWithdrawal(ctx, model.Withdrawal{UserID: userID, Order: number, Sum: sum})
+	})
+}
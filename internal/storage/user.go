@@ -6,7 +6,9 @@ import (
 )
 
 type UserStorage interface {
+	// GetUserByName пользователь по имени вместе с хешем пароля для проверки при входе
 	GetUserByName(ctx context.Context, name string) (model.User, error)
+	// GetUserByID пользователь по id, PasswordHash может быть не заполнен: кэширующие хранилища хеш не хранят
 	GetUserByID(ctx context.Context, id int64) (model.User, error)
 	RegisterUser(ctx context.Context, user model.User) (model.User, error)
 }
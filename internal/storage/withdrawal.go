@@ -8,5 +8,4 @@ import (
 type WithdrawalStorage interface {
 	GetAllWithdrawalsByUserID(ctx context.Context, id int64) ([]model.Withdrawal, error)
 	GetWithdrawnSumByUserID(ctx context.Context, userID int64) (float64, error)
-	CreateWithdrawal(ctx context.Context, number string, sum float64, userID int64) error
 }
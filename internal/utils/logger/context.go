@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+const (
+	RequestIDKey = "request_id" // RequestIDKey поле идентификатора HTTP или gRPC запроса
+	UserIDKey    = "user_id"    // UserIDKey поле пользователя запроса
+	JobIDKey     = "job_id"     // JobIDKey поле задания фонового воркера
+	TraceIDKey   = "trace_id"   // TraceIDKey поле трассировки OpenTelemetry
+)
+
+type contextKey struct{}
+
+// userValueSetter контекст запроса с пользовательскими значениями, например *fasthttp.RequestCtx
+type userValueSetter interface {
+	SetUserValue(key interface{}, value interface{})
+}
+
+// FromContext логгер запроса или задания из контекста, без него - глобальный Log
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*zap.SugaredLogger); ok {
+			return l
+		}
+	}
+	return Log
+}
+
+// WithContext контекст с логгером l
+func WithContext(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// With контекст с логгером из ctx, дополненным полями args
+func With(ctx context.Context, args ...interface{}) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
+
+// Attach логгер запроса в пользовательских значениях, для *fasthttp.RequestCtx вместо WithContext,
+// так как обработчики получают сам запрос, а не производный контекст
+func Attach(ctx userValueSetter, l *zap.SugaredLogger) {
+	ctx.SetUserValue(contextKey{}, l)
+}
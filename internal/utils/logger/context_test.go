@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	prev := Log
+	Log = zap.New(core).Sugar()
+	defer func() { Log = prev }()
+
+	ctx := With(context.Background(), RequestIDKey, "req-1")
+	ctx = With(ctx, UserIDKey, int64(7))
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantFields map[string]interface{}
+	}{
+		{"#1 no logger in context", context.Background(), map[string]interface{}{}},
+		{"#2 context logger", ctx, map[string]interface{}{RequestIDKey: "req-1", UserIDKey: int64(7)}},
+		{"#3 nil context", nil, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			FromContext(tt.ctx).Info("test")
+			entries := logs.TakeAll()
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tt.wantFields, entries[0].ContextMap())
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	count, err := testutil.GatherAndCount(Registry, "go_goroutines")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
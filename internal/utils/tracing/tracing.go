@@ -0,0 +1,135 @@
+// Package tracing трассировка OpenTelemetry: провайдер с экспортом в OTLP или stdout/файл,
+// распространение контекста по W3C Trace Context и span для контекстов fasthttp
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+)
+
+const (
+	ExporterNone   = "none"   // ExporterNone span не записываются, контекст трассировки только передаётся дальше
+	ExporterOTLP   = "otlp"   // ExporterOTLP экспорт в коллектор по OTLP/gRPC
+	ExporterStdout = "stdout" // ExporterStdout экспорт в stdout или файл в формате JSON для локальной отладки
+)
+
+// instrumentationName имя трассировщика приложения
+const instrumentationName = "github.com/superles/yapgofermart"
+
+// Options настройки экспорта span
+type Options struct {
+	ServiceName string // ServiceName имя сервиса в ресурсе span
+	Exporter    string // Exporter none, otlp или stdout
+	Endpoint    string // Endpoint адрес коллектора OTLP host:port, пустой - из переменных OTEL_EXPORTER_OTLP_*
+	File        string // File файл для экспорта stdout, пустой - stdout процесса
+}
+
+// Init установка глобальных провайдера и распространителя контекста W3C Trace Context и Baggage.
+// Возвращает функцию, которая отправляет накопленные span и останавливает экспорт
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var clientOpts []otlptracegrpc.Option
+		if len(opts.Endpoint) > 0 {
+			clientOpts = append(clientOpts, otlptracegrpc.WithEndpoint(opts.Endpoint))
+		}
+		var err error
+		if exporter, err = otlptracegrpc.New(ctx, clientOpts...); err != nil {
+			return nil, fmt.Errorf("ошибка инициализации экспорта OTLP: %w", err)
+		}
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		if len(opts.File) > 0 {
+			f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка открытия файла трассировки: %w", err)
+			}
+			w, closer = f, f
+		}
+		var err error
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(w)); err != nil {
+			return nil, fmt.Errorf("ошибка инициализации экспорта stdout: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный экспорт трассировки: %s", opts.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Tracer трассировщик приложения из глобального провайдера, до Init span не записываются
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+type spanKey struct{}
+
+// userValueSetter контекст запроса с пользовательскими значениями, например *fasthttp.RequestCtx
+type userValueSetter interface {
+	SetUserValue(key interface{}, value interface{})
+}
+
+// Attach span запроса в пользовательских значениях, для *fasthttp.RequestCtx вместо trace.ContextWithSpan,
+// так как обработчики получают сам запрос, а не производный контекст
+func Attach(ctx userValueSetter, span trace.Span) {
+	ctx.SetUserValue(spanKey{}, span)
+}
+
+// Start дочерний span текущего span из ctx, в том числе добавленного через Attach
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if span, ok := ctx.Value(spanKey{}).(trace.Span); ok {
+			ctx = trace.ContextWithSpan(ctx, span)
+		}
+	}
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End завершение span, ошибка err записывается в span и выставляет статус Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID идентификатор трассировки span для логов, пустой если span не записывается и не продолжает входящую трассировку
+func TraceID(span trace.Span) string {
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useRecorder глобальный провайдер с записью завершённых span на время теста
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func TestStart(t *testing.T) {
+	recorder := useRecorder(t)
+
+	requestCtx := &fasthttp.RequestCtx{}
+	_, parent := Tracer().Start(context.Background(), "request")
+	Attach(requestCtx, parent)
+
+	ctx, child := Start(requestCtx, "storage")
+	_, grandchild := Start(ctx, "query")
+	End(grandchild, nil)
+	End(child, errors.New("ошибка"))
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	traceID := parent.SpanContext().TraceID()
+	assert.Equal(t, traceID, spans[0].SpanContext().TraceID())
+	assert.Equal(t, child.SpanContext().SpanID(), spans[0].Parent().SpanID(), "родитель из производного контекста")
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[1].Parent().SpanID(), "родитель из пользовательских значений")
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, traceID.String(), TraceID(parent))
+}
+
+func TestInit(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+	path := filepath.Join(t.TempDir(), "spans.json")
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"#1 disabled", Options{Exporter: ExporterNone}, false},
+		{"#2 unknown exporter", Options{Exporter: "jaeger"}, true},
+		{"#3 stdout to file", Options{ServiceName: "test", Exporter: ExporterStdout, File: path}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Init(context.Background(), tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_, span := Tracer().Start(context.Background(), "span")
+			span.End()
+			require.NoError(t, shutdown(context.Background()))
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"span"`)
+	assert.Contains(t, string(data), `"Value":"test"`)
+}
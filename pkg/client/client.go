@@ -0,0 +1,237 @@
+// Package client клиент HTTP API накопительной системы лояльности «Гофермарт»
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// Client клиент API. Токен берётся из заголовка Authorization ответов регистрации и входа
+// и обновляется повторным входом, если сервер ответил 401 на запрос с истёкшим токеном
+type Client struct {
+	baseURL  string
+	http     *http.Client
+	language string
+
+	mu       sync.RWMutex
+	token    string
+	login    string
+	password string
+}
+
+// Option настройка клиента
+type Option func(c *Client)
+
+// WithHTTPClient http клиент для запросов вместо http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// WithToken токен уже аутентифицированного пользователя, без логина и пароля он не обновляется
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithLanguage язык сообщений об ошибках в заголовке Accept-Language
+func WithLanguage(language string) Option {
+	return func(c *Client) {
+		c.language = language
+	}
+}
+
+// New клиент сервера по адресу baseURL, например http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimRight(baseURL, "/"), http: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token текущий токен авторизации, пустой до регистрации или входа
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// Register регистрация и вход пользователя
+func (c *Client) Register(ctx context.Context, login string, password string) error {
+	return c.authenticate(ctx, "/api/user/register", login, password)
+}
+
+// Login вход пользователя, логин и пароль запоминаются для обновления токена
+func (c *Client) Login(ctx context.Context, login string, password string) error {
+	return c.authenticate(ctx, "/api/user/login", login, password)
+}
+
+func (c *Client) authenticate(ctx context.Context, path string, login string, password string) error {
+	body, err := json.Marshal(credentials{Login: login, Password: password})
+	if err != nil {
+		return err
+	}
+	res, err := c.send(ctx, http.MethodPost, path, "application/json", body, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return decodeError(res)
+	}
+	if !strings.HasPrefix(res.Header.Get(authorizationHeader), bearerPrefix) {
+		return errors.New("сервер не вернул токен авторизации")
+	}
+
+	c.mu.Lock()
+	c.login, c.password = login, password
+	c.mu.Unlock()
+	return nil
+}
+
+// UploadOrder загрузка номера заказа, created false - заказ уже был загружен этим пользователем
+func (c *Client) UploadOrder(ctx context.Context, number string) (created bool, err error) {
+	res, err := c.do(ctx, http.MethodPost, "/api/user/orders", "text/plain", []byte(number))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusAccepted:
+		return true, nil
+	case http.StatusOK:
+		return false, nil
+	default:
+		return false, decodeError(res)
+	}
+}
+
+// Orders заказы пользователя от новых к старым, пустой список, если заказов нет
+func (c *Client) Orders(ctx context.Context) ([]Order, error) {
+	var orders []Order
+	err := c.getJSON(ctx, "/api/user/orders", &orders)
+	return orders, err
+}
+
+// Balance текущий баланс и сумма списаний за всё время
+func (c *Client) Balance(ctx context.Context) (Balance, error) {
+	var balance Balance
+	err := c.getJSON(ctx, "/api/user/balance", &balance)
+	return balance, err
+}
+
+// Withdraw списание sum баллов в счёт заказа number
+func (c *Client) Withdraw(ctx context.Context, number string, sum float64) error {
+	body, err := json.Marshal(withdrawRequest{Order: number, Sum: sum})
+	if err != nil {
+		return err
+	}
+	res, err := c.do(ctx, http.MethodPost, "/api/user/balance/withdraw", "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return decodeError(res)
+	}
+	return nil
+}
+
+// Withdrawals списания пользователя от новых к старым, пустой список, если списаний нет
+func (c *Client) Withdrawals(ctx context.Context) ([]Withdrawal, error) {
+	var withdrawals []Withdrawal
+	err := c.getJSON(ctx, "/api/user/withdrawals", &withdrawals)
+	return withdrawals, err
+}
+
+// getJSON GET запрос с разбором json ответа в v, ответ 204 оставляет v без изменений
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	res, err := c.do(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			return fmt.Errorf("ошибка разбора ответа %s: %w", path, err)
+		}
+		return nil
+	case http.StatusNoContent:
+		return nil
+	default:
+		return decodeError(res)
+	}
+}
+
+// do запрос с токеном пользователя. При ответе 401 токен обновляется входом с сохранёнными
+// логином и паролем, и запрос повторяется один раз
+func (c *Client) do(ctx context.Context, method string, path string, contentType string, body []byte) (*http.Response, error) {
+	token := c.Token()
+	res, err := c.send(ctx, method, path, contentType, body, token)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	c.mu.RLock()
+	login, password := c.login, c.password
+	c.mu.RUnlock()
+	if len(login) == 0 {
+		return res, nil
+	}
+	_ = res.Body.Close()
+
+	// токен мог обновить параллельный запрос, тогда повторный вход не нужен
+	if c.Token() == token {
+		if err := c.Login(ctx, login, password); err != nil {
+			return nil, fmt.Errorf("ошибка обновления токена: %w", err)
+		}
+	}
+	return c.send(ctx, method, path, contentType, body, c.Token())
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, contentType string, body []byte, token string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(token) > 0 {
+		req.Header.Set(authorizationHeader, bearerPrefix+token)
+	}
+	if len(c.language) > 0 {
+		req.Header.Set("Accept-Language", c.language)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := strings.CutPrefix(res.Header.Get(authorizationHeader), bearerPrefix); ok && len(value) > 0 {
+		c.mu.Lock()
+		c.token = value
+		c.mu.Unlock()
+	}
+	return res, nil
+}
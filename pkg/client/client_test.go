@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superles/yapgofermart/internal/accrual"
+	"github.com/superles/yapgofermart/internal/config"
+	"github.com/superles/yapgofermart/internal/server"
+	"github.com/superles/yapgofermart/internal/storage/memstorage"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// accrualStub система расчёта, начисляющая 100 баллов за любой заказ
+type accrualStub struct{}
+
+func (accrualStub) Get(_ context.Context, number string) (accrual.Accrual, error) {
+	sum := float64(100)
+	return accrual.Accrual{Number: number, Status: accrual.StatusProcessed, Accrual: &sum}, nil
+}
+
+// startServer сервер с хранилищем в памяти на свободном порту, останавливается по окончании теста
+func startServer(t *testing.T) string {
+	store, err := memstorage.NewStorage()
+	require.NoError(t, err, "ошибка инициализации хранилища")
+	cfg := &config.Config{SecretKey: "test", SecretKeyBytes: []byte("test"), OpenAPIValidateResponses: true}
+	service := accrual.Service{Client: accrualStub{}, Storage: store, PoolInterval: 20 * time.Millisecond, Workers: 1}
+	srv := server.New(cfg, store, service)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = srv.Serve(ctx, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return "http://" + ln.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	baseURL := startServer(t)
+	c := New(baseURL, WithLanguage("en"))
+
+	require.NoError(t, c.Register(ctx, "user", "pass"))
+	assert.NotEmpty(t, c.Token())
+	assert.ErrorIs(t, New(baseURL).Register(ctx, "user", "other"), ErrUserExists)
+
+	orders, err := c.Orders(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+
+	created, err := c.UploadOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.True(t, created)
+	created, err = c.UploadOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.False(t, created, "повторная загрузка своего заказа")
+
+	_, err = c.UploadOrder(ctx, "12345678904")
+	assert.ErrorIs(t, err, ErrInvalidOrderNumber)
+
+	other := New(baseURL)
+	require.NoError(t, other.Register(ctx, "other", "pass"))
+	_, err = other.UploadOrder(ctx, "12345678903")
+	assert.ErrorIs(t, err, ErrOrderConflict)
+
+	err = c.Withdraw(ctx, "2377225624", 500)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusPaymentRequired, apiErr.Status)
+	assert.Equal(t, "insufficient balance", apiErr.Detail)
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	require.Eventually(t, func() bool {
+		balance, err := c.Balance(ctx)
+		return err == nil && balance.Current == 100
+	}, 2*time.Second, 20*time.Millisecond, "начисление за заказ")
+
+	orders, err = c.Orders(ctx)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, OrderStatusProcessed, orders[0].Status)
+	require.NotNil(t, orders[0].Accrual)
+	assert.Equal(t, float64(100), *orders[0].Accrual)
+	assert.False(t, orders[0].UploadedAt.IsZero())
+
+	require.NoError(t, c.Withdraw(ctx, "2377225624", 40))
+	balance, err := c.Balance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Current: 60, Withdrawn: 40}, balance)
+
+	withdrawals, err := c.Withdrawals(ctx)
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, "2377225624", withdrawals[0].Order)
+	assert.Equal(t, float64(40), withdrawals[0].Sum)
+}
+
+func TestClient_tokenRefresh(t *testing.T) {
+	ctx := context.Background()
+	baseURL := startServer(t)
+
+	c := New(baseURL)
+	require.NoError(t, c.Register(ctx, "user", "pass"))
+	c.token = "expired"
+
+	_, err := c.Balance(ctx)
+	require.NoError(t, err, "токен обновляется повторным входом")
+	assert.NotEqual(t, "expired", c.Token())
+
+	anonymous := New(baseURL, WithToken("expired"))
+	_, err = anonymous.Balance(ctx)
+	assert.ErrorIs(t, err, ErrUnauthorized, "без логина и пароля токен не обновляется")
+
+	assert.ErrorIs(t, New(baseURL).Login(ctx, "user", "wrong"), ErrInvalidCredentials)
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	errs "github.com/superles/yapgofermart/internal/errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// APIError ответ сервера с ошибкой. Сравнение через errors.Is с ошибками пакета идёт по коду
+type APIError struct {
+	Status    int    // Status HTTP статус ответа
+	Code      string // Code стабильный код ошибки, пустой для ответов не в формате problem+json
+	Detail    string // Detail сообщение для пользователя на языке Accept-Language
+	RequestID string // RequestID идентификатор запроса для поиска в логах сервера
+}
+
+func (e *APIError) Error() string {
+	if len(e.Code) == 0 {
+		return fmt.Sprintf("ошибка API %d: %s", e.Status, e.Detail)
+	}
+	return fmt.Sprintf("ошибка API %d %s: %s", e.Status, e.Code, e.Detail)
+}
+
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && len(t.Code) > 0 && t.Code == e.Code
+}
+
+// Ошибки API для сравнения через errors.Is
+var (
+	ErrBadRequest          = &APIError{Code: errs.CodeBadRequest}
+	ErrInvalidOrderNumber  = &APIError{Code: errs.CodeInvalidOrderNumber}
+	ErrInvalidCredentials  = &APIError{Code: errs.CodeInvalidCredentials}
+	ErrUnauthorized        = &APIError{Code: errs.CodeUnauthorized}
+	ErrUserExists          = &APIError{Code: errs.CodeUserExists}
+	ErrOrderConflict       = &APIError{Code: errs.CodeOrderConflict}
+	ErrInsufficientBalance = &APIError{Code: errs.CodeInsufficientBalance}
+	ErrInternal            = &APIError{Code: errs.CodeInternal}
+)
+
+type problem struct {
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+}
+
+// decodeError ошибка из ответа сервера с неуспешным статусом
+func decodeError(res *http.Response) error {
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения ответа %d: %w", res.StatusCode, err)
+	}
+	apiErr := &APIError{Status: res.StatusCode, Detail: string(data)}
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType == "application/problem+json" {
+		var p problem
+		if err := json.Unmarshal(data, &p); err == nil {
+			apiErr.Code, apiErr.Detail, apiErr.RequestID = p.Code, p.Detail, p.RequestID
+		}
+	}
+	return apiErr
+}
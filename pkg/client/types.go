@@ -0,0 +1,43 @@
+package client
+
+import (
+	"time"
+)
+
+const (
+	OrderStatusNew        = "NEW"        // OrderStatusNew заказ загружен, но не попал в обработку
+	OrderStatusProcessing = "PROCESSING" // OrderStatusProcessing вознаграждение за заказ рассчитывается
+	OrderStatusInvalid    = "INVALID"    // OrderStatusInvalid система расчёта отказала в расчёте
+	OrderStatusProcessed  = "PROCESSED"  // OrderStatusProcessed расчёт начисления завершён
+)
+
+// Order загруженный заказ пользователя
+type Order struct {
+	Number     string    `json:"number"`
+	Status     string    `json:"status"`
+	Accrual    *float64  `json:"accrual,omitempty"` // Accrual начисление, есть только у PROCESSED заказов
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// Balance баланс пользователя
+type Balance struct {
+	Current   float64 `json:"current"`
+	Withdrawn float64 `json:"withdrawn"`
+}
+
+// Withdrawal списание баллов в счёт заказа
+type Withdrawal struct {
+	Order       string    `json:"order"`
+	Sum         float64   `json:"sum"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+type credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type withdrawRequest struct {
+	Order string  `json:"order"`
+	Sum   float64 `json:"sum"`
+}
@@ -7,11 +7,11 @@ import (
 	"github.com/superles/yapgofermart/internal/config"
 	"github.com/superles/yapgofermart/internal/model"
 	"github.com/superles/yapgofermart/internal/server"
+	"github.com/superles/yapgofermart/internal/service"
 	"github.com/superles/yapgofermart/internal/storage"
 	"github.com/superles/yapgofermart/internal/storage/memstorage"
 	"github.com/superles/yapgofermart/internal/utils/logger"
-	"net/http"
-	"strings"
+	"github.com/superles/yapgofermart/pkg/client"
 	"testing"
 	"time"
 
@@ -21,7 +21,7 @@ import (
 type clientTest struct {
 }
 
-func (c clientTest) Get(number string) (accrual.Accrual, error) {
+func (c clientTest) Get(_ context.Context, number string) (accrual.Accrual, error) {
 	sum := float64(100)
 	return accrual.Accrual{Number: number, Status: accrual.StatusProcessed, Accrual: &sum}, nil
 }
@@ -63,7 +63,7 @@ func (suite *APITestSuite) registerTestUsers() {
 	}
 	var returnUsers []model.User
 	for _, user := range users {
-		hashPass, err := server.HashPasswordWithRandomSalt(user.Password)
+		hashPass, err := service.HashPasswordWithRandomSalt(user.Password)
 		suite.Require().NoError(err, "ошибка хеширования пароля")
 		registerUser, err := suite.storage.RegisterUser(suite.appContext, model.User{Name: user.Name, PasswordHash: hashPass})
 		suite.Require().NoError(err, "регистрации пользователя")
@@ -77,6 +77,8 @@ func (suite *APITestSuite) initConfig() {
 	suite.config, err = config.New()
 	suite.Require().NoError(err, "ошибка инициализации конфига")
 	suite.config.LogLevel = "error"
+	// ответы сервера сверяются с api/api.yml, расхождение приходит как 500
+	suite.config.OpenAPIValidateResponses = true
 	if len(suite.config.Endpoint) == 0 {
 		suite.config.Endpoint = "localhost:33190"
 	}
@@ -108,12 +110,15 @@ func (suite *APITestSuite) initServer() {
 	suite.server = server.New(suite.config, suite.storage, suite.service)
 }
 
-func (suite *APITestSuite) getAuthHeader(user model.User) string {
-	var err error
-	var token string
-	token, err = suite.server.GetAuthToken(user)
+// newClient клиент API, аутентифицированный как user
+func (suite *APITestSuite) newClient(user model.User) *client.Client {
+	token, err := suite.server.GetAuthToken(user)
 	suite.Require().NoError(err, "ошибка добавления тестового токена user")
-	return fmt.Sprintf("Bearer %s", token)
+	return client.New(suite.baseURL(), client.WithToken(token))
+}
+
+func (suite *APITestSuite) baseURL() string {
+	return fmt.Sprintf("http://%s", suite.config.Endpoint)
 }
 
 func (suite *APITestSuite) SetupTest() {
@@ -138,98 +143,54 @@ func (suite *APITestSuite) TearDownTest() {
 
 // Теста для роута /api/user/register
 func (suite *APITestSuite) TestUserRegister() {
-	client := http.Client{}
-	body := strings.NewReader(`{ "login":"user3", "password":"pass3" }`)
-	url := fmt.Sprintf("http://%s%s", suite.config.Endpoint, "/api/user/register")
-	req, err := http.NewRequest(http.MethodPost, url, body)
-	suite.Require().NoError(err, "ошибка создания запроса")
-	req.Header.Set("Content-Type", "application/json")
-	res, err := client.Do(req)
-	suite.Require().NoError(err, "ошибка запроса")
-	defer res.Body.Close()
-	suite.Require().Exactlyf(http.StatusOK, res.StatusCode, "неожиданный статус")
-	_, err = suite.storage.GetUserByName(suite.appContext, "user")
+	err := client.New(suite.baseURL()).Register(suite.appContext, "user3", "pass3")
+	suite.Require().NoError(err, "ошибка регистрации")
+	_, err = suite.storage.GetUserByName(suite.appContext, "user3")
 	suite.Require().NoError(err, "ошибка проверки наличия пользователя в бд")
 
 }
 
 // Теста для роута /api/user/login
 func (suite *APITestSuite) TestUserLogin() {
-	client := http.Client{}
-	body := strings.NewReader(`{ "login":"user", "password":"pass" }`)
-	url := fmt.Sprintf("http://%s%s", suite.config.Endpoint, "/api/user/login")
-	req, err := http.NewRequest(http.MethodPost, url, body)
-	suite.Require().NoError(err, "ошибка создания запроса")
-	req.Header.Set("Content-Type", "application/json")
-	res, err := client.Do(req)
-	suite.Require().NoError(err, "ошибка запроса")
-	defer res.Body.Close()
-	suite.Require().Exactlyf(http.StatusOK, res.StatusCode, "неожиданный статус")
-	_, err = suite.storage.GetUserByName(suite.appContext, "user")
-	suite.Require().NoError(err, "ошибка проверки наличия пользователя в бд")
+	c := client.New(suite.baseURL())
+	err := c.Login(suite.appContext, "user", "pass")
+	suite.Require().NoError(err, "ошибка входа")
+	suite.Require().NotEmpty(c.Token(), "нет токена после входа")
 
 }
 
 func (suite *APITestSuite) TestCreateOrder() {
-	client := http.Client{}
 	orderNumber := `123456789049`
-	body := strings.NewReader(orderNumber)
-	url := fmt.Sprintf("http://%s%s", suite.config.Endpoint, "/api/user/orders")
-	req, err := http.NewRequest(http.MethodPost, url, body)
-	suite.Require().NoError(err, "ошибка создания запроса")
-	req.Header.Set("Content-Type", "text/plain")
-	req.Header.Set("Authorization", suite.getAuthHeader(suite.getFirstUser()))
-	res, err := client.Do(req)
-	suite.Require().NoError(err, "ошибка запроса")
-	defer res.Body.Close()
-	suite.Require().Exactlyf(http.StatusAccepted, res.StatusCode, "неожиданный статус")
+	created, err := suite.newClient(suite.getFirstUser()).UploadOrder(suite.appContext, orderNumber)
+	suite.Require().NoError(err, "ошибка загрузки заказа")
+	suite.Require().True(created, "заказ не принят в обработку")
 	_, err = suite.storage.GetOrder(suite.appContext, orderNumber)
 	suite.Require().NoError(err, "ошибка проверки наличия заказа в бд")
 
 }
 
 func (suite *APITestSuite) TestBalanceChangedAfterOrderCreate() {
-	client := http.Client{}
 	orderNumber := `123456789049`
-	body := strings.NewReader(orderNumber)
-	url := fmt.Sprintf("http://%s%s", suite.config.Endpoint, "/api/user/orders")
-	req, err := http.NewRequest(http.MethodPost, url, body)
-	suite.Require().NoError(err, "ошибка создания запроса")
-	req.Header.Set("Content-Type", "text/plain")
-	req.Header.Set("Authorization", suite.getAuthHeader(suite.getFirstUser()))
-	res, err := client.Do(req)
-	suite.Require().NoError(err, "ошибка запроса")
-	defer res.Body.Close()
-	suite.Require().Exactlyf(http.StatusAccepted, res.StatusCode, "неожиданный статус")
-	var order model.Order
-	order, err = suite.storage.GetOrder(suite.appContext, orderNumber)
-	suite.Require().NoError(err, "ошибка проверки наличия пользователя в бд")
+	c := suite.newClient(suite.getFirstUser())
+	created, err := c.UploadOrder(suite.appContext, orderNumber)
+	suite.Require().NoError(err, "ошибка загрузки заказа")
+	suite.Require().True(created, "заказ не принят в обработку")
 	time.Sleep(1 * time.Second) // ожидание обработки сервиса
-	var orderOwner model.User
-	orderOwner, err = suite.storage.GetUserByID(suite.appContext, order.UserID)
-	suite.Require().NoError(err, "ошибка проверки наличия заказа в бд")
-	suite.Require().Exactlyf(float64(100), orderOwner.Balance, "неправильно начисленный баланс")
+	balance, err := c.Balance(suite.appContext)
+	suite.Require().NoError(err, "ошибка запроса баланса")
+	suite.Require().Exactlyf(float64(100), balance.Current, "неправильно начисленный баланс")
 
 }
 
 func (suite *APITestSuite) TestBalanceChangedAfterOrderAndWithdraw() {
 	suite.TestBalanceChangedAfterOrderCreate()
-	client := http.Client{}
-	bodyString := `{ "order": "2377225624", "sum": 50 }`
-	body := strings.NewReader(bodyString)
-	url := fmt.Sprintf("http://%s%s", suite.config.Endpoint, "/api/user/balance/withdraw")
-	req, err := http.NewRequest(http.MethodPost, url, body)
-	suite.Require().NoError(err, "ошибка создания запроса")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", suite.getAuthHeader(suite.getFirstUser()))
-	res, err := client.Do(req)
-	suite.Require().NoError(err, "ошибка запроса")
-	defer res.Body.Close()
-	suite.Require().Exactlyf(http.StatusOK, res.StatusCode, "неожиданный статус")
-	var orderOwner model.User
-	orderOwner, err = suite.storage.GetUserByID(suite.appContext, suite.getFirstUser().ID)
-	suite.Require().NoError(err, "ошибка проверки наличия пользователя в бд")
-	suite.Require().Exactlyf(float64(50), orderOwner.Balance, "неправильно начисленный баланс")
+	c := suite.newClient(suite.getFirstUser())
+	err := c.Withdraw(suite.appContext, "2377225624", 50)
+	suite.Require().NoError(err, "ошибка списания")
+	balance, err := c.Balance(suite.appContext)
+	suite.Require().NoError(err, "ошибка запроса баланса")
+	suite.Require().Exactlyf(float64(50), balance.Current, "неправильно начисленный баланс")
+	suite.Require().Exactlyf(float64(50), balance.Withdrawn, "неправильная сумма списаний")
 
 }
 